@@ -1,81 +1,286 @@
 package shardedmap
 
 import (
-	"errors"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // NewAtomicShard creates a new AtomicShard.
 func NewAtomicShard() Shard {
 	s := new(AtomicShard)
-	s.data.Store(make(ShardDataMap))
+	s.state.Store(&atomicShardState{data: make(ShardDataMap), count: 0})
 
 	return s
 }
 
-// AtomicShard represents a shard used in Map.
+// AtomicShard represents a copy-on-write shard used in Map.
+//
+// Readers load the current state with a single atomic operation and never block. Writers are serialized,
+// copy the current data, apply their change to the copy and publish it atomically. Published data is never
+// modified again. Every write copies the whole shard, so AtomicShard suits read-mostly workloads.
 type AtomicShard struct {
-	mu   sync.RWMutex
-	data atomic.Value
+	mu         sync.Mutex
+	state      atomic.Pointer[atomicShardState]
+	maxEntries uint
+	onEvict    EvictionHandler
+}
+
+// atomicShardState is the immutable data published by an AtomicShard.
+type atomicShardState struct {
+	data  ShardDataMap
+	count uint
 }
 
 func (s *AtomicShard) getValueMap() ShardDataMap {
-	m1 := s.data.Load().(ShardDataMap) // nolint:forcetypeassert
+	return s.state.Load().data
+}
+
+// update applies fn to a copy of the current data and publishes the copy if fn reports a change.
+// fn returns the change of the element count and whether the data was changed.
+func (s *AtomicShard) update(fn func(data ShardDataMap) (delta int, changed bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return m1
+	current := s.state.Load()
+	data := current.data.Clone()
+
+	if delta, changed := fn(data); changed {
+		s.state.Store(&atomicShardState{data: data, count: uint(int(current.count) + delta)})
+	}
 }
 
-// All see: interfaces.Shard.
-func (s *AtomicShard) All() ShardDataMap {
+// Configure see: interfaces.ConfigurableShard.
+func (s *AtomicShard) Configure(cfg ShardConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maxEntries = cfg.MaxEntries
+	s.onEvict = cfg.OnEvict
+}
+
+// Snapshot see: interfaces.Shard. It returns the current published version of the data without copying.
+func (s *AtomicShard) Snapshot() ShardDataMap {
 	return s.getValueMap()
 }
 
 // Get see: interfaces.Shard.
-func (s *AtomicShard) Get(key uint) (interface{}, error) {
-	tuple, ok := s.getValueMap()[key]
-	if !ok {
-		return nil, errors.New("not found") // nolint
+func (s *AtomicShard) Get(keyHash uint, key string) (interface{}, error) {
+	tuple, err := s.GetTuple(keyHash, key)
+	if err != nil {
+		return nil, err
 	}
 
 	return tuple.GetValue(), nil
 }
 
-// Set see: interfaces.Shard.
-func (s *AtomicShard) Set(key uint, value ShardTuple) {
-	m1 := s.getValueMap()
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// GetTuple see: interfaces.TupleGetter.
+func (s *AtomicShard) GetTuple(keyHash uint, key string) (ShardTuple, error) {
+	tuple, ok := s.getValueMap().Lookup(keyHash, key)
+	if err := lookupError(key, tuple, ok); err != nil {
+		return nil, err
+	}
 
-	m1[key] = value
+	return tuple, nil
+}
 
-	s.data.Store(m1)
+// Set see: interfaces.Shard.
+func (s *AtomicShard) Set(keyHash uint, tuple ShardTuple) error {
+	_, err := s.Compute(keyHash, tuple.GetKey(), func(ShardTuple) (ShardTuple, bool) {
+		return tuple, true
+	})
+
+	return err
 }
 
 // Has see: interfaces.Shard.
-func (s *AtomicShard) Has(key uint) bool {
-	if _, ok := s.getValueMap()[key]; ok {
-		return true
-	}
+func (s *AtomicShard) Has(keyHash uint, key string) bool {
+	tuple, ok := s.getValueMap().Lookup(keyHash, key)
 
-	return false
+	return ok && !isExpiredNow(tuple)
 }
 
 // Remove see: interfaces.Shard.
-func (s *AtomicShard) Remove(key uint) {
-	m1 := s.getValueMap()
-	delete(m1, key)
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.data.Store(m1)
+func (s *AtomicShard) Remove(keyHash uint, key string) {
+	// Avoid copying the shard if there is nothing to remove
+	if _, ok := s.getValueMap().Lookup(keyHash, key); !ok {
+		return
+	}
+
+	var removed ShardTuple
+
+	s.update(func(data ShardDataMap) (int, bool) {
+		var ok bool
+		removed, ok = data.Delete(keyHash, key)
+
+		return -1, ok
+	})
+
+	if removed != nil {
+		s.onEvict.notify(EvictionReasonRemoved, removed)
+	}
 }
 
 // Count see: interfaces.Shard.
 func (s *AtomicShard) Count() uint {
-	return uint(len(s.getValueMap()))
+	return s.state.Load().count
+}
+
+// RemoveExpired see: interfaces.Shard.
+func (s *AtomicShard) RemoveExpired(now int64) uint {
+	// Avoid copying the shard if nothing is expired
+	var found bool
+
+	s.getValueMap().Range(func(_ uint, tuple ShardTuple) bool {
+		found = IsExpired(tuple, now)
+
+		return !found
+	})
+
+	if !found {
+		return 0
+	}
+
+	var expired []ShardTuple
+
+	s.update(func(data ShardDataMap) (int, bool) {
+		expired = data.DeleteExpired(now)
+
+		return -len(expired), len(expired) > 0
+	})
+
+	s.onEvict.notify(EvictionReasonExpired, expired...)
+
+	return uint(len(expired))
 }
 
 // Clear see: interfaces.Shard.
 func (s *AtomicShard) Clear() {
-	s.data.Store(make(ShardDataMap))
+	s.mu.Lock()
+	cleared := s.state.Swap(&atomicShardState{data: make(ShardDataMap), count: 0})
+	s.mu.Unlock()
+
+	if s.onEvict != nil {
+		cleared.data.Range(func(_ uint, tuple ShardTuple) bool {
+			s.onEvict.notify(EvictionReasonCleared, tuple)
+
+			return true
+		})
+	}
+}
+
+// Compute see: interfaces.Shard. The shard is only copied if fn requests a write.
+func (s *AtomicShard) Compute(keyHash uint, key string, fn ComputeFunc) (ShardTuple, error) {
+	res := func() computeResult {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		current := s.state.Load()
+
+		var data ShardDataMap
+
+		full := s.maxEntries > 0 && current.count >= s.maxEntries
+		res := current.data.compute(keyHash, key, fn, func() ShardDataMap {
+			data = current.data.Clone()
+
+			return data
+		}, full)
+
+		if data != nil {
+			s.state.Store(&atomicShardState{data: data, count: uint(int(current.count) + res.delta)})
+		}
+
+		return res
+	}()
+
+	if res.dropped != nil {
+		s.onEvict.notify(res.reason, res.dropped)
+	}
+
+	return res.tuple, res.err
+}
+
+// GetMany see: interfaces.BatchShard.
+func (s *AtomicShard) GetMany(keyHashes []uint, keys []string, fn func(i int, value interface{})) {
+	data := s.getValueMap()
+	now := time.Now().UnixNano()
+
+	for i, key := range keys {
+		if tuple, ok := data.Lookup(keyHashes[i], key); ok && !IsExpired(tuple, now) {
+			fn(i, tuple.GetValue())
+		}
+	}
+}
+
+// SetMany see: interfaces.BatchShard. The shard is copied once for all tuples.
+func (s *AtomicShard) SetMany(keyHashes []uint, tuples []ShardTuple) []error {
+	var (
+		errs    []error
+		expired []ShardTuple
+	)
+
+	now := time.Now().UnixNano()
+
+	s.mu.Lock()
+
+	current := s.state.Load()
+	data := current.data.Clone()
+	count := current.count
+
+	for i, tuple := range tuples {
+		if s.maxEntries > 0 && count >= s.maxEntries {
+			if _, ok := data.Lookup(keyHashes[i], tuple.GetKey()); !ok {
+				if errs == nil {
+					errs = make([]error, len(tuples))
+				}
+
+				errs[i] = newKeyError(tuple.GetKey(), ErrCapacity)
+
+				continue
+			}
+		}
+
+		previous, replaced := data.Store(keyHashes[i], tuple)
+
+		switch {
+		case !replaced:
+			count++
+		case IsExpired(previous, now):
+			expired = append(expired, previous)
+		}
+	}
+
+	s.state.Store(&atomicShardState{data: data, count: count})
+	s.mu.Unlock()
+
+	s.onEvict.notify(EvictionReasonExpired, expired...)
+
+	return errs
+}
+
+// RemoveMany see: interfaces.BatchShard. The shard is copied once for all keys.
+func (s *AtomicShard) RemoveMany(keyHashes []uint, keys []string, fn func(i int)) {
+	var removed, expired []ShardTuple
+
+	s.update(func(data ShardDataMap) (int, bool) {
+		now := time.Now().UnixNano()
+
+		for i, key := range keys {
+			if tuple, ok := data.Delete(keyHashes[i], key); ok {
+				if IsExpired(tuple, now) {
+					expired = append(expired, tuple)
+				} else {
+					removed = append(removed, tuple)
+					fn(i)
+				}
+			}
+		}
+
+		deleted := len(removed) + len(expired)
+
+		return -deleted, deleted > 0
+	})
+
+	s.onEvict.notify(EvictionReasonRemoved, removed...)
+	s.onEvict.notify(EvictionReasonExpired, expired...)
 }
@@ -1,11 +1,89 @@
 package shardedmap_test
 
 import (
+	"fmt"
 	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"sync"
 	"testing"
 )
 
 func TestAtomicShardTestsInSuite(t *testing.T) {
 	suite.Run(t, NewShardTestSuite(shardedmap.NewAtomicShard()))
 }
+
+// Run with -race to detect readers observing data that is modified by writers.
+func TestAtomicShardConcurrentReadersAndWriters(t *testing.T) {
+	const writers, readers, iterations = 4, 4, 500
+
+	shard := shardedmap.NewAtomicShard()
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				key := fmt.Sprintf("%d-%d", w, i%10)
+				keyHash := shardedmap.HashFnv1a64(key)
+
+				shard.Set(keyHash, shardedmap.NewTuple(key, i))
+				shard.Compute(keyHash, key, func(current shardedmap.ShardTuple) (shardedmap.ShardTuple, bool) {
+					return current, false
+				})
+
+				if i%3 == 0 {
+					shard.Remove(keyHash, key)
+				}
+
+				if i%100 == 0 {
+					shard.Clear()
+				}
+			}
+		}(w)
+	}
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				key := fmt.Sprintf("%d-%d", i%writers, i%10)
+				_, _ = shard.Get(shardedmap.HashFnv1a64(key), key)
+
+				data := shard.Snapshot()
+				count := 0
+				data.Range(func(_ uint, tuple shardedmap.ShardTuple) bool {
+					count++
+
+					return tuple.GetKey() != ""
+				})
+
+				// A published state never changes
+				again := 0
+				data.Range(func(_ uint, _ shardedmap.ShardTuple) bool {
+					again++
+
+					return true
+				})
+				assert.Equal(t, count, again)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	count := 0
+	shard.Snapshot().Range(func(_ uint, _ shardedmap.ShardTuple) bool {
+		count++
+
+		return true
+	})
+	assert.Equal(t, count, int(shard.Count()))
+}
@@ -0,0 +1,341 @@
+package shardedmap
+
+import (
+	"sync"
+)
+
+// shardBatch holds the keys of a batch operation that belong to the same shard.
+type shardBatch struct {
+	shard *shardSlot
+	// positions holds the position of every key in the input of the operation.
+	positions []int
+	keyHashes []uint
+	keys      []string
+	// err is the first error of a write to the shard.
+	err error
+}
+
+// fail keeps err if it is the first error of the batch.
+func (b *shardBatch) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// encodeRecords returns the log records returned by encode for the keys of b. Keys whose record cannot be
+// encoded are dropped from b and their error is kept in b, so they are not changed.
+func (b *shardBatch) encodeRecords(encode func(key string) ([]byte, error)) [][]byte {
+	records := make([][]byte, 0, len(b.keys))
+	kept := 0
+
+	for j, key := range b.keys {
+		record, err := encode(key)
+		if err != nil {
+			b.fail(err)
+
+			continue
+		}
+
+		b.positions[kept], b.keyHashes[kept], b.keys[kept] = b.positions[j], b.keyHashes[j], key
+		records = append(records, record)
+		kept++
+	}
+
+	b.positions, b.keyHashes, b.keys = b.positions[:kept], b.keyHashes[:kept], b.keys[:kept]
+
+	return records
+}
+
+// groupByShard hashes every key once and groups the keys by their shard in t.
+func (m *Map) groupByShard(t *shardTable, keys []string) []*shardBatch {
+	byIndex := make([]*shardBatch, t.count)
+	batches := make([]*shardBatch, 0, t.count)
+
+	for i, key := range keys {
+		keyHash := m.getKeyHash(key)
+		index := t.index(keyHash)
+
+		b := byIndex[index]
+		if b == nil {
+			b = &shardBatch{shard: t.shards[index]} //nolint:exhaustivestruct
+			byIndex[index] = b
+			batches = append(batches, b)
+		}
+
+		b.positions = append(b.positions, i)
+		b.keyHashes = append(b.keyHashes, keyHash)
+		b.keys = append(b.keys, key)
+	}
+
+	return batches
+}
+
+// runBatches calls fn for every batch. Up to the number of workers set by WithBatchWorkers are processed in
+// parallel.
+func (m *Map) runBatches(batches []*shardBatch, fn func(b *shardBatch)) {
+	workers := m.batchWorkers
+	if workers > len(batches) {
+		workers = len(batches)
+	}
+
+	if workers <= 1 {
+		for _, b := range batches {
+			fn(b)
+		}
+
+		return
+	}
+
+	queue := make(chan *shardBatch)
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for b := range queue {
+				fn(b)
+			}
+		}()
+	}
+
+	for _, b := range batches {
+		queue <- b
+	}
+
+	close(queue)
+	wg.Wait()
+}
+
+// firstError returns the first error of batches.
+func firstError(batches []*shardBatch) error {
+	for _, b := range batches {
+		if b.err != nil {
+			return b.err
+		}
+	}
+
+	return nil
+}
+
+// lockBatch locks the gate of the shard of b for modifying it, see shardSlot.lockGate. If the shard was evacuated
+// by a concurrent Resize, it returns false and the keys of b must be processed one by one.
+func (m *Map) lockBatch(b *shardBatch, exclusive bool) bool {
+	b.shard.lockGate(exclusive)
+
+	if b.shard.evacuated.Load() {
+		b.shard.unlockGate(exclusive)
+
+		return false
+	}
+
+	return true
+}
+
+// getMany calls fn with the position and value of every key of b that is present and not expired.
+func (m *Map) getMany(b *shardBatch, fn func(i int, value interface{})) {
+	// Like readShard, the shard is read without a lock and a concurrent evacuation does not matter
+	if b.shard.evacuated.Load() {
+		for j, key := range b.keys {
+			if value, err := m.Get(key); err == nil {
+				fn(b.positions[j], value)
+			}
+		}
+
+		return
+	}
+
+	found := make([]bool, len(b.keys))
+
+	if shard, ok := b.shard.Shard.(BatchShard); ok {
+		shard.GetMany(b.keyHashes, b.keys, func(j int, value interface{}) {
+			found[j] = true
+
+			fn(b.positions[j], value)
+		})
+	} else {
+		for j, key := range b.keys {
+			if value, err := b.shard.Get(b.keyHashes[j], key); err == nil {
+				found[j] = true
+
+				fn(b.positions[j], value)
+			}
+		}
+	}
+
+	for _, ok := range found {
+		b.shard.stats.lookup(ok)
+	}
+}
+
+// GetMany returns the values of all keys that are present and not expired. The keys are grouped by shard, so
+// every shard is accessed once. See WithBatchWorkers to process the shards in parallel.
+func (m *Map) GetMany(keys []string) map[string]interface{} {
+	values := make([]interface{}, len(keys))
+	found := make([]bool, len(keys))
+
+	m.runBatches(m.groupByShard(m.table.Load(), keys), func(b *shardBatch) {
+		m.getMany(b, func(i int, value interface{}) {
+			values[i], found[i] = value, true
+		})
+	})
+
+	result := make(map[string]interface{}, len(keys))
+
+	for i, key := range keys {
+		if found[i] {
+			result[key] = values[i]
+		}
+	}
+
+	return result
+}
+
+// HasMany reports for every key whether it is present and not expired, see GetMany.
+func (m *Map) HasMany(keys []string) map[string]bool {
+	found := make([]bool, len(keys))
+
+	m.runBatches(m.groupByShard(m.table.Load(), keys), func(b *shardBatch) {
+		m.getMany(b, func(i int, _ interface{}) {
+			found[i] = true
+		})
+	})
+
+	result := make(map[string]bool, len(keys))
+
+	for i, key := range keys {
+		result[key] = result[key] || found[i]
+	}
+
+	return result
+}
+
+// SetMany sets all entries like Set. The keys are grouped by shard and every shard is locked once for all of
+// its keys. See WithBatchWorkers to process the shards in parallel. A resize in progress is completed first.
+//
+// SetMany is not atomic: other operations may observe some of the entries before all are set. If entries do
+// not fit into their shard, the remaining entries are still set and the first error is returned.
+func (m *Map) SetMany(entries map[string]interface{}) error {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+
+	expiry := m.expiryFromTTL(m.defaultTTL)
+	batches := m.groupByShard(m.stableTable(), keys)
+
+	m.runBatches(batches, func(b *shardBatch) {
+		// The gate is locked exclusively, so the tuples can be stamped before they are passed to the shard
+		if !m.lockBatch(b, true) {
+			for _, key := range b.keys {
+				if err := m.storeTuple(NewTupleWithExpiry(key, entries[key], expiry)); err != nil {
+					b.fail(err)
+				}
+			}
+
+			return
+		}
+
+		var stored []ShardTuple
+
+		defer func() {
+			b.shard.gate.Unlock()
+			m.growIfNeeded(b.shard)
+
+			for _, tuple := range stored {
+				m.notifySet(tuple)
+			}
+		}()
+
+		tuples := make([]ShardTuple, 0, len(b.keys))
+		records := b.encodeRecords(func(key string) ([]byte, error) {
+			tuple := b.shard.stamp(NewTupleWithExpiry(key, entries[key], expiry))
+
+			record, err := m.encodeSet(tuple)
+			if err == nil {
+				tuples = append(tuples, tuple)
+			}
+
+			return record, err
+		})
+
+		var errs []error
+
+		if shard, ok := b.shard.Shard.(BatchShard); ok {
+			errs = shard.SetMany(b.keyHashes, tuples)
+		} else {
+			for j, tuple := range tuples {
+				if err := b.shard.Set(b.keyHashes[j], tuple); err != nil {
+					if errs == nil {
+						errs = make([]error, len(tuples))
+					}
+
+					errs[j] = err
+				}
+			}
+		}
+
+		for j, tuple := range tuples {
+			if errs != nil && errs[j] != nil {
+				b.fail(errs[j])
+
+				continue
+			}
+
+			b.shard.stats.set()
+
+			if err := m.appendLog(records[j]); err != nil {
+				b.fail(err)
+
+				continue
+			}
+
+			stored = append(stored, tuple)
+		}
+	})
+
+	return firstError(batches)
+}
+
+// RemoveMany removes all keys like Remove. The keys are grouped by shard and every shard is locked once for all
+// of its keys. See WithBatchWorkers to process the shards in parallel. A resize in progress is completed first.
+func (m *Map) RemoveMany(keys []string) {
+	m.runBatches(m.groupByShard(m.stableTable(), keys), func(b *shardBatch) {
+		if !m.lockBatch(b, m.wal != nil) {
+			for _, key := range b.keys {
+				m.Remove(key)
+			}
+
+			return
+		}
+
+		defer m.unlockShard(b.shard)
+
+		records := b.encodeRecords(m.encodeRemove)
+
+		// Like Remove, only the removal of present entries is counted and logged
+		var removed []int
+
+		if shard, ok := b.shard.Shard.(BatchShard); ok {
+			shard.RemoveMany(b.keyHashes, b.keys, func(j int) {
+				removed = append(removed, j)
+			})
+		} else {
+			for j, key := range b.keys {
+				if removeKey(b.shard.Shard, b.keyHashes[j], key) {
+					removed = append(removed, j)
+				}
+			}
+		}
+
+		b.shard.stats.removeMany(len(removed))
+
+		for _, j := range removed {
+			// A failed append is returned by the next write
+			_ = m.appendLog(records[j])
+		}
+	})
+}
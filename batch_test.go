@@ -0,0 +1,104 @@
+package shardedmap_test
+
+import (
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func batchTestEntries(n int) (map[string]interface{}, []string) {
+	entries := make(map[string]interface{}, n)
+	keys := make([]string, 0, n)
+
+	for i := 0; i < n; i++ {
+		key := fmt.Sprint(i)
+		entries[key] = i
+		keys = append(keys, key)
+	}
+
+	return entries, keys
+}
+
+func TestBatchOperations(t *testing.T) {
+	for _, provider := range resizeTestProviders {
+		for _, workers := range []int{0, 4} {
+			instance := shardedmap.New(
+				shardedmap.WithShardCount(8),
+				shardedmap.WithCustomShardProvider(provider),
+				shardedmap.WithBatchWorkers(workers),
+				shardedmap.WithStats(),
+			)
+
+			entries, keys := batchTestEntries(1000)
+
+			assert.NoError(t, instance.SetMany(entries))
+			assert.Equal(t, 1000, instance.Count())
+			assert.Equal(t, entries, instance.All())
+
+			assert.Equal(t, map[string]interface{}{"1": 1, "2": 2}, instance.GetMany([]string{"1", "2", "missing"}))
+			assert.Equal(t, entries, instance.GetMany(keys))
+			assert.Equal(t, map[string]bool{"1": true, "missing": false}, instance.HasMany([]string{"1", "missing"}))
+
+			instance.RemoveMany(keys[:500])
+			assert.Equal(t, 500, instance.Count())
+			assert.Len(t, instance.GetMany(keys), 500)
+
+			stats := instance.Stats().Total
+			assert.Equal(t, uint64(1000), stats.Sets)
+			assert.Equal(t, uint64(500), stats.Removes)
+			assert.Equal(t, uint64(1503), stats.Hits)
+			assert.Equal(t, uint64(502), stats.Misses)
+		}
+	}
+}
+
+func TestSetManyCapacity(t *testing.T) {
+	for _, provider := range []shardedmap.ShardProviderFunc{shardedmap.NewMutexShard, shardedmap.NewAtomicShard} {
+		instance := shardedmap.New(
+			shardedmap.WithShardCount(1),
+			shardedmap.WithMaxEntries(10),
+			shardedmap.WithCustomShardProvider(provider),
+		)
+
+		entries, _ := batchTestEntries(20)
+
+		assert.ErrorIs(t, instance.SetMany(entries), shardedmap.ErrCapacity)
+		assert.Equal(t, 10, instance.Count())
+	}
+}
+
+func TestSetManyWithConcurrentResize(t *testing.T) {
+	instance := shardedmap.New(shardedmap.WithShardCount(1), shardedmap.WithBatchWorkers(2))
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			entries := make(map[string]interface{})
+			keys := make([]string, 0, 100)
+
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("%d-%d", w, i)
+				entries[key] = i
+				keys = append(keys, key)
+			}
+
+			assert.NoError(t, instance.SetMany(entries))
+			assert.Len(t, instance.GetMany(keys), 100)
+		}(w)
+	}
+
+	for _, count := range []int{4, 16, 3} {
+		assert.NoError(t, instance.Resize(count))
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 400, instance.Count())
+}
@@ -279,3 +279,197 @@ func Benchmark_ShardedMap_Parallel_ShardCount_32__Provider_Atomic__Hash_32__Get(
 func Benchmark_ShardedMap_Parallel_ShardCount_32__Provider_Atomic__Hash_64__Get(b *testing.B) {
 	runParallelBenchmarkGet(b, 32, shardedmap.NewAtomicShard, shardedmap.HashFnv1a64)
 }
+
+// runParallelBenchmarkReadMostly performs one Set for every readsPerWrite Gets.
+func runParallelBenchmarkReadMostly(b *testing.B, shardProvider shardedmap.ShardProviderFunc, readsPerWrite int) {
+	b.Helper()
+
+	instance := shardedmap.New(
+		shardedmap.WithShardCount(32),
+		shardedmap.WithCustomShardProvider(shardProvider),
+	)
+	testData := gofakeit.Map()
+
+	for k, v := range testData {
+		instance.Set(k, v)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		randomKey := pickRandomKeyFromDataSet(testData)
+		i := 0
+
+		for pb.Next() {
+			if i%(readsPerWrite+1) == 0 {
+				instance.Set(randomKey, testData[randomKey])
+			} else if v := instance.MustGet(randomKey); v == nil {
+				b.FailNow()
+			}
+			i++
+		}
+	})
+	// Give go some time to breath
+	b.StopTimer()
+	runtime.GC()
+	time.Sleep(sleepAfterBenchmarkDuration)
+}
+
+func Benchmark_ShardedMap_Parallel_ShardCount_32__Provider_Mutex__ReadMostly_100(b *testing.B) {
+	runParallelBenchmarkReadMostly(b, shardedmap.NewMutexShard, 100)
+}
+
+func Benchmark_ShardedMap_Parallel_ShardCount_32__Provider_Atomic__ReadMostly_100(b *testing.B) {
+	runParallelBenchmarkReadMostly(b, shardedmap.NewAtomicShard, 100)
+}
+
+func Benchmark_ShardedMap_Parallel_ShardCount_32__Provider_Mutex__ReadMostly_10(b *testing.B) {
+	runParallelBenchmarkReadMostly(b, shardedmap.NewMutexShard, 10)
+}
+
+func Benchmark_ShardedMap_Parallel_ShardCount_32__Provider_Atomic__ReadMostly_10(b *testing.B) {
+	runParallelBenchmarkReadMostly(b, shardedmap.NewAtomicShard, 10)
+}
+
+// runParallelBenchmarkIndexing spreads Gets and Sets over all keys, so every goroutine accesses all shards.
+func runParallelBenchmarkIndexing(b *testing.B, opts ...shardedmap.MapOption) {
+	b.Helper()
+
+	instance := shardedmap.New(opts...)
+	testData := gofakeit.Map()
+	keys := make([]string, 0, len(testData))
+
+	for k, v := range testData {
+		instance.Set(k, v)
+		keys = append(keys, k)
+	}
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := rand.Intn(len(keys)) //nolint:gosec
+
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if i%4 == 0 {
+				instance.Set(key, testData[key])
+			} else if v := instance.MustGet(key); v == nil {
+				b.FailNow()
+			}
+			i++
+		}
+	})
+	// Give go some time to breath
+	b.StopTimer()
+	runtime.GC()
+	time.Sleep(sleepAfterBenchmarkDuration)
+}
+
+func Benchmark_ShardedMap_Parallel_ShardCount_32__Provider_Mutex__Modulo__Indexing(b *testing.B) {
+	runParallelBenchmarkIndexing(b, shardedmap.WithShardCount(32))
+}
+
+func Benchmark_ShardedMap_Parallel_ShardCount_32__Provider_Mutex__PowerOfTwo__Indexing(b *testing.B) {
+	runParallelBenchmarkIndexing(b, shardedmap.WithShardCount(32), shardedmap.WithPowerOfTwoShards())
+}
+
+func Benchmark_ShardedMap_Parallel_ShardCount_128__Provider_Mutex__Modulo__Indexing(b *testing.B) {
+	runParallelBenchmarkIndexing(b, shardedmap.WithShardCount(128))
+}
+
+func Benchmark_ShardedMap_Parallel_ShardCount_128__Provider_Mutex__PowerOfTwo__Indexing(b *testing.B) {
+	runParallelBenchmarkIndexing(b, shardedmap.WithShardCount(128), shardedmap.WithPowerOfTwoShards())
+}
+
+// Benchmark_ShardedMap_Parallel_ShardCount_32__Provider_Mutex__WithStats compares to the Modulo__Indexing benchmark
+// with 32 shards, which runs without statistics.
+func Benchmark_ShardedMap_Parallel_ShardCount_32__Provider_Mutex__WithStats(b *testing.B) {
+	runParallelBenchmarkIndexing(b, shardedmap.WithShardCount(32), shardedmap.WithStats())
+}
+
+func runBenchmarkKeyHashFunc(b *testing.B, keyHashFunc shardedmap.KeyHashFunc) {
+	b.Helper()
+
+	key := gofakeit.UUID()
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		keyHashFunc(key)
+	}
+}
+
+func Benchmark_KeyHashFunc_Fnv1a64(b *testing.B) {
+	runBenchmarkKeyHashFunc(b, shardedmap.HashFnv1a64)
+}
+
+func Benchmark_KeyHashFunc_XXHash64(b *testing.B) {
+	runBenchmarkKeyHashFunc(b, shardedmap.HashXXHash64)
+}
+
+func Benchmark_KeyHashFunc_CRC32C(b *testing.B) {
+	runBenchmarkKeyHashFunc(b, shardedmap.HashCRC32C)
+}
+
+func Benchmark_KeyHashFunc_Maphash(b *testing.B) {
+	runBenchmarkKeyHashFunc(b, shardedmap.NewHashMaphash())
+}
+
+func runBenchmarkBatch(b *testing.B, batch bool, opts ...shardedmap.MapOption) {
+	b.Helper()
+
+	const size = 10000
+
+	entries := make(map[string]interface{}, size)
+	keys := make([]string, 0, size)
+
+	for i := 0; i < size; i++ {
+		key := gofakeit.UUID()
+		entries[key] = i
+		keys = append(keys, key)
+	}
+
+	instance := shardedmap.New(opts...)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if batch {
+			_ = instance.SetMany(entries)
+			instance.GetMany(keys)
+
+			continue
+		}
+
+		for key, value := range entries {
+			_ = instance.Set(key, value)
+		}
+
+		for _, key := range keys {
+			instance.MustGet(key)
+		}
+	}
+}
+
+func Benchmark_ShardedMap_ShardCount_32__Provider_Mutex__PerKey(b *testing.B) {
+	runBenchmarkBatch(b, false, shardedmap.WithShardCount(32))
+}
+
+func Benchmark_ShardedMap_ShardCount_32__Provider_Mutex__Batch(b *testing.B) {
+	runBenchmarkBatch(b, true, shardedmap.WithShardCount(32))
+}
+
+func Benchmark_ShardedMap_ShardCount_32__Provider_Mutex__Batch_Workers_4(b *testing.B) {
+	runBenchmarkBatch(b, true, shardedmap.WithShardCount(32), shardedmap.WithBatchWorkers(4))
+}
+
+func Benchmark_ShardedMap_ShardCount_32__Provider_Atomic__PerKey(b *testing.B) {
+	runBenchmarkBatch(
+		b, false, shardedmap.WithShardCount(32), shardedmap.WithCustomShardProvider(shardedmap.NewAtomicShard),
+	)
+}
+
+func Benchmark_ShardedMap_ShardCount_32__Provider_Atomic__Batch(b *testing.B) {
+	runBenchmarkBatch(
+		b, true, shardedmap.WithShardCount(32), shardedmap.WithCustomShardProvider(shardedmap.NewAtomicShard),
+	)
+}
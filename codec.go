@@ -0,0 +1,76 @@
+package shardedmap
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// ValueCodec encodes and decodes the values of a Map for persistence, see Map.WriteSnapshot.
+type ValueCodec interface {
+	// Name identifies the codec. It is stored in snapshots to detect snapshots written with another codec.
+	Name() string
+
+	// Encode returns the encoded value.
+	Encode(value interface{}) ([]byte, error)
+
+	// Decode returns the value encoded in data.
+	Decode(data []byte) (interface{}, error)
+}
+
+// GobCodec encodes values with encoding/gob. Values keep their type, but types other than the predeclared types
+// must be registered with gob.Register. It is the default ValueCodec.
+type GobCodec struct{}
+
+// Name see: interfaces.ValueCodec.
+func (GobCodec) Name() string {
+	return "gob"
+}
+
+// Encode see: interfaces.ValueCodec.
+func (GobCodec) Encode(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	// Encoding a pointer to the interface transmits the concrete type of the value
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decode see: interfaces.ValueCodec.
+func (GobCodec) Decode(data []byte) (interface{}, error) {
+	var value interface{}
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// JSONCodec encodes values with encoding/json. Like Map.UnmarshalJSON, it decodes numbers as float64 and objects
+// as map[string]interface{}.
+type JSONCodec struct{}
+
+// Name see: interfaces.ValueCodec.
+func (JSONCodec) Name() string {
+	return "json"
+}
+
+// Encode see: interfaces.ValueCodec.
+func (JSONCodec) Encode(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Decode see: interfaces.ValueCodec.
+func (JSONCodec) Decode(data []byte) (interface{}, error) {
+	var value interface{}
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
@@ -0,0 +1,263 @@
+package shardedmap
+
+// Every function in this file runs under a single shard lock, so it is atomic with respect to all other
+// operations on the same key. New values expire after the default TTL like values added by Set.
+// Functions that may add a key return ErrCapacity if the shard of the key is full and cannot evict entries.
+// Changes are appended to the write-ahead log of Maps created by Open. Changes that cannot be logged are not
+// applied, see Open. Functions without an error result then report that nothing changed. If only the append
+// fails after the change was applied, they cannot report it. The log is then broken, and the next write with an
+// error result returns the error.
+
+// GetOrSet returns the existing value for key if present. Otherwise, it stores and returns value.
+// The loaded result is true if the value was loaded, false if stored.
+func (m *Map) GetOrSet(key string, value interface{}) (actual interface{}, loaded bool, err error) {
+	var stored ShardTuple
+
+	keyHash, shard := m.getKeyHashAndLockShardFromKey(key)
+	defer m.releaseAndNotify(shard, &stored)
+
+	var (
+		record []byte
+		logErr error
+	)
+
+	tuple, err := shard.Compute(keyHash, key, func(current ShardTuple) (ShardTuple, bool) {
+		if current != nil {
+			loaded = true
+
+			return current, false
+		}
+
+		tuple := m.newTuple(key, value)
+		if record, logErr = m.encodeSet(tuple); logErr != nil {
+			return nil, false
+		}
+
+		return shard.stamp(tuple), true
+	})
+	if err == nil {
+		err = logErr
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	shard.stats.lookup(loaded)
+
+	if !loaded {
+		shard.stats.set()
+
+		if err := m.appendLog(record); err != nil {
+			return nil, false, err
+		}
+
+		stored = tuple
+	}
+
+	return tuple.GetValue(), loaded, nil
+}
+
+// SetIfAbsent sets the value for key only if key is absent and reports whether the value was set.
+func (m *Map) SetIfAbsent(key string, value interface{}) (bool, error) {
+	_, loaded, err := m.GetOrSet(key, value)
+
+	return !loaded && err == nil, err
+}
+
+// CompareAndSwap swaps the old and new values for key if the value stored for key is equal to old.
+// The old value must be of a comparable type. It returns false if the change cannot be logged.
+func (m *Map) CompareAndSwap(key string, old, new interface{}) (swapped bool) { //nolint:predeclared
+	var (
+		stored ShardTuple
+		record []byte
+	)
+
+	keyHash, shard := m.getKeyHashAndLockShardFromKey(key)
+	defer m.releaseAndNotify(shard, &stored)
+
+	// Only existing keys are replaced, so Compute cannot fail
+	tuple, _ := shard.Compute(keyHash, key, func(current ShardTuple) (ShardTuple, bool) {
+		if current == nil || current.GetValue() != old {
+			return current, false
+		}
+
+		tuple := m.newTuple(key, new)
+
+		var err error
+		if record, err = m.encodeSet(tuple); err != nil {
+			return current, false
+		}
+
+		swapped = true
+
+		return shard.stamp(tuple), true
+	})
+
+	if swapped {
+		shard.stats.set()
+
+		if m.appendLog(record) == nil {
+			stored = tuple
+		}
+	}
+
+	return swapped
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+// The old value must be of a comparable type. It returns false if the change cannot be logged.
+func (m *Map) CompareAndDelete(key string, old interface{}) (deleted bool) {
+	keyHash, shard := m.getKeyHashAndLockShardFromKey(key)
+	defer m.releaseShard(shard)
+
+	record, err := m.encodeRemove(key)
+	if err != nil {
+		return false
+	}
+
+	// Removing a key cannot fail
+	_, _ = shard.Compute(keyHash, key, func(current ShardTuple) (ShardTuple, bool) {
+		if current == nil || current.GetValue() != old {
+			return current, false
+		}
+
+		deleted = true
+
+		return nil, true
+	})
+
+	if deleted {
+		shard.stats.remove()
+
+		_ = m.appendLog(record)
+	}
+
+	return deleted
+}
+
+// Upsert stores the value returned by fn for key and returns it. fn receives the current value and whether
+// the key exists. fn runs while the shard of key is locked, so it must not access the Map.
+func (m *Map) Upsert(key string, fn func(old interface{}, exists bool) interface{}) (interface{}, error) {
+	var stored ShardTuple
+
+	keyHash, shard := m.getKeyHashAndLockShardFromKey(key)
+	defer m.releaseAndNotify(shard, &stored)
+
+	var (
+		record []byte
+		logErr error
+	)
+
+	tuple, err := shard.Compute(keyHash, key, func(current ShardTuple) (ShardTuple, bool) {
+		var tuple ShardTuple
+
+		if current == nil {
+			tuple = m.newTuple(key, fn(nil, false))
+		} else {
+			tuple = m.newTuple(key, fn(current.GetValue(), true))
+		}
+
+		if record, logErr = m.encodeSet(tuple); logErr != nil {
+			return current, false
+		}
+
+		return shard.stamp(tuple), true
+	})
+	if err == nil {
+		err = logErr
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	shard.stats.set()
+
+	if err := m.appendLog(record); err != nil {
+		return nil, err
+	}
+
+	stored = tuple
+
+	return tuple.GetValue(), nil
+}
+
+// Compute updates the entry for key with the result of fn. If fn returns keep = false the entry is removed.
+// Compute returns the new value and whether the key exists afterwards. fn runs while the shard of key is locked,
+// so it must not access the Map.
+func (m *Map) Compute(
+	key string,
+	fn func(old interface{}, exists bool) (newValue interface{}, keep bool),
+) (interface{}, bool, error) {
+	var stored ShardTuple
+
+	keyHash, shard := m.getKeyHashAndLockShardFromKey(key)
+	defer m.releaseAndNotify(shard, &stored)
+
+	var (
+		record  []byte
+		logErr  error
+		removed bool
+	)
+
+	tuple, err := shard.Compute(keyHash, key, func(current ShardTuple) (ShardTuple, bool) {
+		var (
+			newValue interface{}
+			keep     bool
+		)
+
+		if current == nil {
+			newValue, keep = fn(nil, false)
+		} else {
+			newValue, keep = fn(current.GetValue(), true)
+		}
+
+		// Removing an absent key changes nothing that needs to be logged
+		if !keep && current == nil {
+			return nil, true
+		}
+
+		if !keep {
+			if record, logErr = m.encodeRemove(key); logErr != nil {
+				return current, false
+			}
+
+			removed = true
+
+			return nil, true
+		}
+
+		tuple := m.newTuple(key, newValue)
+		if record, logErr = m.encodeSet(tuple); logErr != nil {
+			return current, false
+		}
+
+		return shard.stamp(tuple), true
+	})
+	if err == nil {
+		err = logErr
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if tuple == nil {
+		if removed {
+			shard.stats.remove()
+		}
+
+		return nil, false, m.appendLog(record)
+	}
+
+	shard.stats.set()
+
+	if err := m.appendLog(record); err != nil {
+		return nil, false, err
+	}
+
+	stored = tuple
+
+	return tuple.GetValue(), true, nil
+}
@@ -0,0 +1,152 @@
+package shardedmap_test
+
+import (
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func (s *MapTestSuite) TestGetOrSet() {
+	k := pickRandomKeyFromDataSet(s.testDataSet)
+	actual, loaded, err := s.instance.GetOrSet(k, "new")
+	s.NoError(err)
+	s.True(loaded)
+	s.Equal(s.testDataSet[k], actual)
+
+	actual, loaded, err = s.instance.GetOrSet("____new_key", "new")
+	s.NoError(err)
+	s.False(loaded)
+	s.Equal("new", actual)
+	s.Equal("new", s.instance.MustGet("____new_key"))
+
+	set, err := s.instance.SetIfAbsent("____new_key", "other")
+	s.NoError(err)
+	s.False(set)
+
+	set, err = s.instance.SetIfAbsent("____other_key", "other")
+	s.NoError(err)
+	s.True(set)
+	s.Equal("new", s.instance.MustGet("____new_key"))
+	s.Equal("other", s.instance.MustGet("____other_key"))
+}
+
+func (s *MapTestSuite) TestCompareAndSwap() {
+	s.instance.Set("____key", 1)
+
+	s.False(s.instance.CompareAndSwap("____key", 2, 3))
+	s.True(s.instance.CompareAndSwap("____key", 1, 3))
+	s.Equal(3, s.instance.MustGet("____key"))
+	s.False(s.instance.CompareAndSwap("____not_existing_key", nil, 3))
+
+	s.False(s.instance.CompareAndDelete("____key", 1))
+	s.True(s.instance.CompareAndDelete("____key", 3))
+	s.False(s.instance.Has("____key"))
+}
+
+func (s *MapTestSuite) TestUpsertAndCompute() {
+	appendValue := func(old interface{}, exists bool) interface{} {
+		if !exists {
+			return []int{1}
+		}
+
+		return append(old.([]int), len(old.([]int))+1) //nolint:forcetypeassert
+	}
+
+	v, err := s.instance.Upsert("____key", appendValue)
+	s.NoError(err)
+	s.Equal([]int{1}, v)
+
+	v, err = s.instance.Upsert("____key", appendValue)
+	s.NoError(err)
+	s.Equal([]int{1, 2}, v)
+
+	v, ok, err := s.instance.Compute("____key", func(old interface{}, exists bool) (interface{}, bool) {
+		s.True(exists)
+
+		return len(old.([]int)), true //nolint:forcetypeassert
+	})
+	s.NoError(err)
+	s.True(ok)
+	s.Equal(2, v)
+
+	v, ok, err = s.instance.Compute("____key", func(old interface{}, exists bool) (interface{}, bool) {
+		return nil, false
+	})
+	s.NoError(err)
+	s.False(ok)
+	s.Nil(v)
+	s.False(s.instance.Has("____key"))
+	s.Equal(len(s.testDataSet), s.instance.Count())
+}
+
+func (s *ShardTestSuite) TestCompute() {
+	keyHash := shardedmap.HashFnv1a64("__key")
+
+	tuple, err := s.instance.Compute(keyHash, "__key", func(current shardedmap.ShardTuple) (shardedmap.ShardTuple, bool) {
+		s.Nil(current)
+
+		return shardedmap.NewTuple("__key", 1), true
+	})
+	s.NoError(err)
+	s.Equal(1, tuple.GetValue())
+
+	tuple, err = s.instance.Compute(keyHash, "__key", func(current shardedmap.ShardTuple) (shardedmap.ShardTuple, bool) {
+		return shardedmap.NewTuple("__key", 2), false
+	})
+	s.NoError(err)
+	s.Equal(1, tuple.GetValue())
+
+	tuple, err = s.instance.Compute(keyHash, "__key", func(current shardedmap.ShardTuple) (shardedmap.ShardTuple, bool) {
+		return nil, true
+	})
+	s.NoError(err)
+	s.Nil(tuple)
+	s.False(s.instance.Has(keyHash, "__key"))
+	s.Equal(len(s.testDataSet), int(s.instance.Count()))
+}
+
+func TestUpsertIsAtomic(t *testing.T) {
+	const goroutines, increments = 8, 1000
+
+	for _, provider := range []shardedmap.ShardProviderFunc{
+		shardedmap.NewMutexShard,
+		shardedmap.NewAtomicShard,
+		shardedmap.NewLRUShard,
+	} {
+		instance := shardedmap.New(shardedmap.WithCustomShardProvider(provider))
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < goroutines; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				for j := 0; j < increments; j++ {
+					_, _ = instance.Upsert("counter", func(old interface{}, exists bool) interface{} {
+						if !exists {
+							return 1
+						}
+
+						return old.(int) + 1 //nolint:forcetypeassert
+					})
+				}
+			}()
+		}
+
+		wg.Wait()
+		assert.Equal(t, goroutines*increments, instance.MustGet("counter"))
+	}
+}
+
+func TestComputeReportsEvictions(t *testing.T) {
+	recorder := newEvictionRecorder()
+	instance := shardedmap.New(shardedmap.WithOnEvict(recorder.onEvict))
+
+	instance.Set("key", 1)
+	assert.True(t, instance.CompareAndDelete("key", 1))
+
+	assert.Equal(t, map[string]shardedmap.EvictionReason{"key": shardedmap.EvictionReasonRemoved}, recorder.get())
+}
@@ -0,0 +1,13 @@
+package shardedmap
+
+import (
+	"hash/crc32"
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli) //nolint:gochecknoglobals
+
+// HashCRC32C hashes key with CRC-32C (Castagnoli). It uses the CRC32 instructions of the CPU where available
+// (SSE 4.2 on amd64, CRC on arm64). Like HashFnv1a32, it produces 32 bit hashes.
+func HashCRC32C(key string) uint {
+	return uint(crc32.Checksum(stringBytes(key), castagnoliTable))
+}
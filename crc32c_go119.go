@@ -0,0 +1,8 @@
+//go:build !go1.20
+
+package shardedmap
+
+// stringBytes returns the bytes of s. unsafe.StringData is not available before Go 1.20, so they are copied.
+func stringBytes(s string) []byte {
+	return []byte(s)
+}
@@ -0,0 +1,12 @@
+//go:build go1.20
+
+package shardedmap
+
+import (
+	"unsafe"
+)
+
+// stringBytes returns the bytes of s without copying them. The bytes must not be modified.
+func stringBytes(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s)) //nolint:gosec
+}
@@ -0,0 +1,84 @@
+package shardedmap
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler. The entries are encoded in the format of WriteSnapshot.
+func (m *Map) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := m.WriteSnapshot(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It adds the entries encoded by MarshalBinary,
+// see ReadSnapshot.
+func (m *Map) UnmarshalBinary(data []byte) error {
+	m.initEmpty()
+
+	return m.ReadSnapshot(bytes.NewReader(data))
+}
+
+// GobEncode implements gob.GobEncoder, see MarshalBinary. Values are encoded with the ValueCodec of the Map,
+// so types stored as interface{} values must be registered with gob.Register when using GobCodec.
+func (m *Map) GobEncode() ([]byte, error) {
+	return m.MarshalBinary()
+}
+
+// GobDecode implements gob.GobDecoder, see UnmarshalBinary.
+func (m *Map) GobDecode(data []byte) error {
+	return m.UnmarshalBinary(data)
+}
+
+// MarshalYAML implements yaml.Marshaler. The Map is encoded as a mapping of its entries.
+func (m *Map) MarshalYAML() (interface{}, error) {
+	return m.All(), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler. It sets the entries of a mapping. Values are decoded like yaml.v3
+// decodes into an interface{}. A null node sets nothing.
+func (m *Map) UnmarshalYAML(value *yaml.Node) error {
+	m.initEmpty()
+
+	if value.Kind == yaml.AliasNode {
+		value = value.Alias
+	}
+
+	if value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+		return nil
+	}
+
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("yaml: line %d: cannot unmarshal %s into a Map", value.Line, value.Tag) //nolint:goerr113
+	}
+
+	// Check all members before the first entry is set
+	entries := make([]ShardTuple, 0, len(value.Content)/2) //nolint:gomnd
+
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		var key string
+		if err := value.Content[i].Decode(&key); err != nil {
+			return err
+		}
+
+		var v interface{}
+		if err := value.Content[i+1].Decode(&v); err != nil {
+			return newKeyError(key, err)
+		}
+
+		entries = append(entries, NewTuple(key, v))
+	}
+
+	for _, entry := range entries {
+		if err := m.Set(entry.GetKey(), entry.GetValue()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
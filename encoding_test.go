@@ -0,0 +1,93 @@
+package shardedmap_test
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"testing"
+	"time"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*shardedmap.Map)(nil)
+	_ encoding.BinaryUnmarshaler = (*shardedmap.Map)(nil)
+	_ gob.GobEncoder             = (*shardedmap.Map)(nil)
+	_ gob.GobDecoder             = (*shardedmap.Map)(nil)
+	_ yaml.Marshaler             = (*shardedmap.Map)(nil)
+	_ yaml.Unmarshaler           = (*shardedmap.Map)(nil)
+)
+
+func TestMarshalBinary(t *testing.T) {
+	source := shardedmap.New()
+	assert.NoError(t, source.Set("a", persistedValue{Name: "a", Count: 1}))
+	assert.NoError(t, source.SetWithTTL("b", 2, time.Hour))
+
+	data, err := source.MarshalBinary()
+	assert.NoError(t, err)
+
+	// A zero Map can be decoded into
+	var target shardedmap.Map
+	assert.NoError(t, target.UnmarshalBinary(data))
+
+	assert.Equal(t, 2, target.Count())
+	assert.Equal(t, persistedValue{Name: "a", Count: 1}, target.MustGet("a"))
+
+	assert.Equal(t, 2, target.MustGet("b"))
+
+	assert.ErrorIs(t, target.UnmarshalBinary(data[:len(data)-1]), shardedmap.ErrInvalidSnapshot)
+}
+
+func TestGobEncodeEmbeddedMap(t *testing.T) {
+	type payload struct {
+		ID      int
+		Entries *shardedmap.Map
+	}
+
+	source := payload{ID: 1, Entries: shardedmap.New()}
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, source.Entries.Set(fmt.Sprint(i), i))
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&buf).Encode(source))
+
+	var target payload
+	assert.NoError(t, gob.NewDecoder(&buf).Decode(&target))
+
+	assert.Equal(t, 1, target.ID)
+	assert.Equal(t, source.Entries.All(), target.Entries.All())
+}
+
+func TestMarshalYAML(t *testing.T) {
+	type config struct {
+		Name     string          `yaml:"name"`
+		Settings *shardedmap.Map `yaml:"settings"`
+	}
+
+	source := config{Name: "service", Settings: shardedmap.New()}
+	assert.NoError(t, source.Settings.Set("port", 8080))
+	assert.NoError(t, source.Settings.Set("host", "localhost"))
+
+	data, err := yaml.Marshal(source)
+	assert.NoError(t, err)
+	assert.Equal(t, "name: service\nsettings:\n    host: localhost\n    port: 8080\n", string(data))
+
+	var target config
+	assert.NoError(t, yaml.Unmarshal(data, &target))
+
+	assert.Equal(t, "service", target.Name)
+	assert.Equal(t, source.Settings.All(), target.Settings.All())
+}
+
+func TestUnmarshalYAMLRejectsNonMappings(t *testing.T) {
+	instance := shardedmap.New()
+
+	assert.Error(t, yaml.Unmarshal([]byte("- a\n- b\n"), instance))
+	assert.NoError(t, yaml.Unmarshal([]byte("~\n"), instance))
+	assert.Equal(t, 0, instance.Count())
+}
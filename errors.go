@@ -0,0 +1,60 @@
+package shardedmap
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotFound is returned if a key does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrExpired is returned if a key exists, but its TTL has passed. It matches ErrNotFound with errors.Is.
+	ErrExpired = fmt.Errorf("%w: expired", ErrNotFound)
+	// ErrCapacity is returned if a shard that cannot evict entries is full and a new key should be added.
+	ErrCapacity = errors.New("capacity exceeded")
+	// ErrClosed is returned by writes to a Map created by Open once it has been closed.
+	ErrClosed = errors.New("map closed")
+	// ErrInvalidShardCount is returned if a Map should be resized to less than one shard.
+	ErrInvalidShardCount = errors.New("invalid shard count")
+	// ErrInvalidSnapshot is returned if a snapshot cannot be read because it is corrupted or incompatible.
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+	// ErrTxDone is returned by the operations of a Tx after its Update returned.
+	ErrTxDone = errors.New("transaction done")
+	// ErrConflict is returned by Map.UpdateOptimistic if the keys read by the transaction kept being modified.
+	ErrConflict = errors.New("transaction conflict")
+
+	errBadMagic         = errors.New("bad magic")
+	errChecksumMismatch = errors.New("checksum mismatch")
+	errFieldTooLarge    = errors.New("field too large")
+	errTxRetry          = errors.New("transaction must be retried")
+)
+
+// KeyError records an error and the key that caused it.
+type KeyError struct {
+	Key string
+	Err error
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("key %q: %s", e.Key, e.Err)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+func newKeyError(key string, err error) error {
+	return &KeyError{Key: key, Err: err}
+}
+
+// lookupError returns the error for looking up key if the lookup did not find a valid tuple, otherwise nil.
+func lookupError(key string, tuple ShardTuple, found bool) error {
+	switch {
+	case !found:
+		return newKeyError(key, ErrNotFound)
+	case isExpiredNow(tuple):
+		return newKeyError(key, ErrExpired)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,73 @@
+package shardedmap_test
+
+import (
+	"errors"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLookupErrors(t *testing.T) {
+	for _, provider := range []shardedmap.ShardProviderFunc{
+		shardedmap.NewMutexShard,
+		shardedmap.NewAtomicShard,
+		shardedmap.NewLRUShard,
+	} {
+		instance := shardedmap.New(shardedmap.WithCustomShardProvider(provider))
+
+		_, err := instance.Get("missing")
+		assert.ErrorIs(t, err, shardedmap.ErrNotFound)
+		assert.NotErrorIs(t, err, shardedmap.ErrExpired)
+
+		var keyErr *shardedmap.KeyError
+		assert.True(t, errors.As(err, &keyErr))
+		assert.Equal(t, "missing", keyErr.Key)
+		assert.Equal(t, `key "missing": not found`, err.Error())
+
+		assert.NoError(t, instance.SetWithTTL("expired", 1, time.Nanosecond))
+		time.Sleep(time.Millisecond)
+
+		_, err = instance.Get("expired")
+		assert.ErrorIs(t, err, shardedmap.ErrExpired)
+		assert.ErrorIs(t, err, shardedmap.ErrNotFound)
+	}
+}
+
+func TestCapacityErrors(t *testing.T) {
+	for _, provider := range []shardedmap.ShardProviderFunc{shardedmap.NewMutexShard, shardedmap.NewAtomicShard} {
+		instance := shardedmap.New(
+			shardedmap.WithShardCount(1),
+			shardedmap.WithCustomShardProvider(provider),
+			shardedmap.WithMaxEntries(2),
+		)
+
+		assert.NoError(t, instance.Set("a", 1))
+		assert.NoError(t, instance.Set("b", 2))
+
+		err := instance.Set("c", 3)
+		assert.ErrorIs(t, err, shardedmap.ErrCapacity)
+		assert.False(t, instance.Has("c"))
+
+		_, _, err = instance.GetOrSet("c", 3)
+		assert.ErrorIs(t, err, shardedmap.ErrCapacity)
+
+		_, err = instance.Upsert("c", func(interface{}, bool) interface{} { return 3 })
+		assert.ErrorIs(t, err, shardedmap.ErrCapacity)
+
+		// Existing keys can still be replaced
+		assert.NoError(t, instance.Set("a", 10))
+		assert.True(t, instance.CompareAndSwap("b", 2, 20))
+
+		instance.Remove("a")
+		assert.NoError(t, instance.Set("c", 3))
+		assert.Equal(t, 2, instance.Count())
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	instance := shardedmap.New(shardedmap.WithJanitor(time.Second))
+
+	assert.NoError(t, instance.Close())
+	assert.NoError(t, instance.Close())
+}
@@ -0,0 +1,183 @@
+package shardedmap
+
+//go:generate stringer -type=EvictionReason -trimprefix=EvictionReason
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+)
+
+// EvictionReason describes why an entry left a Map.
+type EvictionReason int
+
+const (
+	// EvictionReasonRemoved is used for entries removed by Map.Remove.
+	EvictionReasonRemoved EvictionReason = iota
+	// EvictionReasonCleared is used for entries removed by Map.Clear.
+	EvictionReasonCleared
+	// EvictionReasonExpired is used for entries removed after their TTL passed.
+	EvictionReasonExpired
+	// EvictionReasonCapacity is used for entries evicted to stay within the capacity of a shard.
+	EvictionReasonCapacity
+)
+
+// EvictionFunc defines the callback that is invoked for entries leaving a Map.
+type EvictionFunc func(key string, value interface{}, reason EvictionReason)
+
+// EvictionHandler is called by shards for every tuple that leaves the shard.
+// Shards must not hold their lock while calling it, so it is safe to access the Map from the handler.
+type EvictionHandler func(tuple ShardTuple, reason EvictionReason)
+
+// notify calls the handler for every tuple. It is a no-op for a nil handler.
+func (h EvictionHandler) notify(reason EvictionReason, tuples ...ShardTuple) {
+	if h == nil {
+		return
+	}
+
+	for _, tuple := range tuples {
+		h(tuple, reason)
+	}
+}
+
+type evictionEvent struct {
+	tuple  ShardTuple
+	reason EvictionReason
+}
+
+// evictionHandler returns the handler passed to shards. It publishes the evictions to the subscribers of Watch
+// and delivers them to the registered callback.
+func (m *Map) evictionHandler() EvictionHandler {
+	var deliver EvictionHandler
+
+	switch {
+	case m.onEvict == nil:
+	case m.evictionQueueSize > 0:
+		deliver = m.enqueueEviction
+	default:
+		deliver = func(tuple ShardTuple, reason EvictionReason) {
+			m.onEvict(tuple.GetKey(), tuple.GetValue(), reason)
+		}
+	}
+
+	return func(tuple ShardTuple, reason EvictionReason) {
+		m.watchers.publishEviction(tuple, reason)
+		deliver.notify(reason, tuple)
+	}
+}
+
+// deferrable wraps the handler of the shard in s, so the evictions are buffered while deferring is set.
+func (s *shardSlot) deferrable(handler EvictionHandler) EvictionHandler {
+	return func(tuple ShardTuple, reason EvictionReason) {
+		if s.deferring {
+			s.deferred = append(s.deferred, evictionEvent{tuple: tuple, reason: reason})
+
+			return
+		}
+
+		handler(tuple, reason)
+	}
+}
+
+// startEvictionQueue starts the goroutine delivering evictions if asynchronous delivery is enabled.
+func (m *Map) startEvictionQueue() {
+	if m.onEvict == nil || m.evictionQueueSize <= 0 {
+		return
+	}
+
+	queue := make(chan evictionEvent, m.evictionQueueSize)
+	m.evictionQueue = queue
+	m.evictionDone = make(chan struct{})
+
+	go func() {
+		defer close(m.evictionDone)
+
+		m.evictionDrainer.Store(goroutineID())
+
+		for e := range queue {
+			m.evictionBusy.Store(true)
+			m.onEvict(e.tuple.GetKey(), e.tuple.GetValue(), e.reason)
+
+			// Deliver the evictions caused by the callback, including those caused by their callbacks
+			for len(m.evictionBacklog) > 0 {
+				next := m.evictionBacklog[0]
+				m.evictionBacklog = m.evictionBacklog[1:]
+				m.onEvict(next.tuple.GetKey(), next.tuple.GetValue(), next.reason)
+			}
+
+			m.evictionBacklog = nil
+			m.evictionBusy.Store(false)
+		}
+	}()
+}
+
+// enqueueEviction blocks while the queue is full. Once the queue is stopped evictions are delivered synchronously.
+//
+// A callback that modifies the Map may cause evictions itself. Waiting for the queue would then deadlock, because
+// the goroutine draining the queue is the one waiting. So evictions caused by a callback are kept in a backlog of
+// the draining goroutine and delivered once the callback returns. Callbacks never run concurrently.
+func (m *Map) enqueueEviction(tuple ShardTuple, reason EvictionReason) {
+	e := evictionEvent{tuple: tuple, reason: reason}
+
+	// The stack is only inspected while a callback runs
+	if m.evictionBusy.Load() && goroutineID() == m.evictionDrainer.Load() {
+		m.evictionBacklog = append(m.evictionBacklog, e)
+
+		return
+	}
+
+	m.evictionMu.RLock()
+
+	queue := m.evictionQueue
+	if queue != nil {
+		m.evictionSenders.Add(1)
+	}
+
+	m.evictionMu.RUnlock()
+
+	if queue == nil {
+		m.onEvict(tuple.GetKey(), tuple.GetValue(), reason)
+
+		return
+	}
+
+	// The queue is closed once all senders are done, so it is safe to block without holding evictionMu
+	defer m.evictionSenders.Done()
+
+	queue <- e
+}
+
+// stopEvictionQueue delivers all queued evictions and stops the delivering goroutine.
+func (m *Map) stopEvictionQueue() {
+	m.evictionMu.Lock()
+
+	queue := m.evictionQueue
+	m.evictionQueue = nil
+
+	m.evictionMu.Unlock()
+
+	if queue == nil {
+		return
+	}
+
+	// Senders blocked on the full queue finish while the queue is drained
+	m.evictionSenders.Wait()
+	close(queue)
+
+	<-m.evictionDone
+}
+
+// goroutineID returns the id of the calling goroutine from the header of its stack trace, which has the form
+// "goroutine 42 [running]:".
+func goroutineID() uint64 {
+	var buf [64]byte
+
+	header := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	if i := bytes.IndexByte(header, ' '); i >= 0 {
+		header = header[:i]
+	}
+
+	id, _ := strconv.ParseUint(string(header), 10, 64)
+
+	return id
+}
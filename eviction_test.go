@@ -0,0 +1,201 @@
+package shardedmap_test
+
+import (
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type evictionRecorder struct {
+	mu      sync.Mutex
+	reasons map[string]shardedmap.EvictionReason
+}
+
+func newEvictionRecorder() *evictionRecorder {
+	return &evictionRecorder{reasons: make(map[string]shardedmap.EvictionReason)} //nolint:exhaustivestruct
+}
+
+func (r *evictionRecorder) onEvict(key string, _ interface{}, reason shardedmap.EvictionReason) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reasons[key] = reason
+}
+
+func (r *evictionRecorder) get() map[string]shardedmap.EvictionReason {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reasons := make(map[string]shardedmap.EvictionReason, len(r.reasons))
+	for k, v := range r.reasons {
+		reasons[k] = v
+	}
+
+	return reasons
+}
+
+func TestOnEvict(t *testing.T) {
+	providers := []shardedmap.ShardProviderFunc{
+		shardedmap.NewMutexShard,
+		shardedmap.NewAtomicShard,
+		shardedmap.NewLRUShard,
+	}
+
+	for _, provider := range providers {
+		for _, async := range []bool{false, true} {
+			recorder := newEvictionRecorder()
+			opts := []shardedmap.MapOption{
+				shardedmap.WithShardCount(1),
+				shardedmap.WithCustomShardProvider(provider),
+				shardedmap.WithOnEvict(recorder.onEvict),
+				shardedmap.WithJanitor(testTTL),
+			}
+
+			if async {
+				opts = append(opts, shardedmap.WithAsyncEviction(1))
+			}
+
+			instance := shardedmap.New(opts...)
+
+			instance.Set("removed", 1)
+			instance.Remove("removed")
+			instance.Remove("not_existing_key")
+
+			instance.SetWithTTL("expired", 2, testTTL)
+			assert.Eventually(t, func() bool { return !instance.Has("expired") && instance.Count() == 0 }, time.Second, testTTL)
+
+			instance.Set("cleared", 3)
+			instance.Clear()
+
+			instance.Close()
+
+			assert.Equal(t, map[string]shardedmap.EvictionReason{
+				"removed": shardedmap.EvictionReasonRemoved,
+				"expired": shardedmap.EvictionReasonExpired,
+				"cleared": shardedmap.EvictionReasonCleared,
+			}, recorder.get())
+		}
+	}
+}
+
+func TestOnEvictCapacity(t *testing.T) {
+	recorder := newEvictionRecorder()
+	instance := shardedmap.New(
+		shardedmap.WithShardCount(1),
+		shardedmap.WithCustomShardProvider(shardedmap.NewLRUShard),
+		shardedmap.WithMaxEntries(1),
+		shardedmap.WithOnEvict(recorder.onEvict),
+	)
+
+	instance.Set("first", 1)
+	instance.Set("second", 2)
+
+	assert.Equal(t, map[string]shardedmap.EvictionReason{"first": shardedmap.EvictionReasonCapacity}, recorder.get())
+}
+
+func TestOnEvictMayAccessMap(t *testing.T) {
+	var instance *shardedmap.Map
+
+	instance = shardedmap.New(shardedmap.WithOnEvict(func(key string, value interface{}, reason shardedmap.EvictionReason) {
+		if key == "first" {
+			instance.Remove("second")
+		}
+	}))
+
+	instance.Set("first", 1)
+	instance.Set("second", 2)
+	instance.Remove("first")
+
+	assert.Equal(t, 0, instance.Count())
+}
+
+func TestAsyncOnEvictMayCauseEvictions(t *testing.T) {
+	var (
+		instance *shardedmap.Map
+		mu       sync.Mutex
+		evicted  []string
+	)
+
+	done := make(chan struct{})
+
+	instance = shardedmap.New(
+		shardedmap.WithShardCount(1),
+		shardedmap.WithCustomShardProvider(shardedmap.NewLRUShard),
+		shardedmap.WithMaxEntries(1),
+		shardedmap.WithAsyncEviction(1),
+		shardedmap.WithOnEvict(func(key string, value interface{}, reason shardedmap.EvictionReason) {
+			mu.Lock()
+			evicted = append(evicted, key)
+			mu.Unlock()
+
+			// Every Set evicts the previous entry, the second one finds the queue full
+			if key == "first" {
+				_ = instance.Set("third", 3)
+				_ = instance.Set("fourth", 4)
+
+				close(done)
+			}
+		}),
+	)
+
+	_ = instance.Set("first", 1)
+	_ = instance.Set("second", 2)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("eviction callback is blocked")
+	}
+
+	assert.NoError(t, instance.Close())
+	assert.ElementsMatch(t, []string{"first", "second", "third"}, evicted)
+}
+
+func TestEvictionReasonString(t *testing.T) {
+	assert.Equal(t, "Removed", shardedmap.EvictionReasonRemoved.String())
+	assert.Equal(t, "Capacity", shardedmap.EvictionReasonCapacity.String())
+	assert.Equal(t, "EvictionReason(42)", shardedmap.EvictionReason(42).String())
+}
+
+func TestAsyncOnEvictCallbacksDoNotOverlap(t *testing.T) {
+	const writers, keys = 8, 200
+
+	var running, overlaps atomic.Int32
+
+	instance := shardedmap.New(
+		shardedmap.WithAsyncEviction(1),
+		shardedmap.WithOnEvict(func(key string, value interface{}, reason shardedmap.EvictionReason) {
+			if running.Add(1) > 1 {
+				overlaps.Add(1)
+			}
+
+			runtime.Gosched()
+			running.Add(-1)
+		}),
+	)
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < keys; i++ {
+				key := fmt.Sprintf("%d-%d", w, i)
+				assert.NoError(t, instance.Set(key, i))
+				instance.Remove(key)
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	assert.NoError(t, instance.Close())
+
+	assert.Zero(t, overlaps.Load())
+}
@@ -0,0 +1,5 @@
+// Package generic contains a type-parameterised variant of shardedmap.Map.
+//
+// Keys and values keep their static types, so callers do not need type assertions and values are
+// stored without being wrapped into interface{}.
+package generic
@@ -0,0 +1,31 @@
+package generic
+
+import (
+	"github.com/dtomasi/shardedmap"
+)
+
+// Hasher defines a function that is used to create a hash from a given key.
+type Hasher[K comparable] func(key K) uint
+
+// Integer is the constraint for keys supported by IntegerHasher.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// StringHasher hashes string keys using shardedmap.DefaultKeyHashFunc.
+func StringHasher[K ~string](key K) uint {
+	return shardedmap.DefaultKeyHashFunc(string(key))
+}
+
+// IntegerHasher hashes integer keys. Sequential keys are spread across shards by mixing the bits of
+// the key with the finalizer of SplitMix64.
+func IntegerHasher[K Integer](key K) uint {
+	x := uint64(key)
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return uint(x)
+}
@@ -0,0 +1,114 @@
+package generic
+
+import (
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+)
+
+// Map represents the sharded map.
+type Map[K comparable, V any] struct {
+	shards            []Shard[K, V]
+	shardCount        uint
+	shardProviderFunc ShardProviderFunc[K, V]
+	hasher            Hasher[K]
+}
+
+// New creates a new sharded map that uses hasher to distribute keys across shards.
+func New[K comparable, V any](hasher Hasher[K], opts ...MapOption[K, V]) *Map[K, V] {
+	m := &Map[K, V]{ //nolint:exhaustivestruct
+		shardCount:        shardedmap.DefaultShardCount,
+		shardProviderFunc: NewMutexShard[K, V],
+		hasher:            hasher,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.shards = make([]Shard[K, V], m.shardCount)
+
+	for j := 0; j < int(m.shardCount); j++ {
+		m.shards[j] = m.shardProviderFunc()
+	}
+
+	return m
+}
+
+// NewStringMap creates a new sharded map with string keys.
+func NewStringMap[V any](opts ...MapOption[string, V]) *Map[string, V] {
+	return New[string, V](StringHasher[string], opts...)
+}
+
+func (m *Map[K, V]) getShard(key K) Shard[K, V] {
+	return m.shards[m.hasher(key)%m.shardCount]
+}
+
+// Range calls fn for every key and value until fn returns false.
+func (m *Map[K, V]) Range(fn func(key K, value V) bool) {
+	for _, shard := range m.shards {
+		for _, t := range shard.All() {
+			if !fn(t.GetKey(), t.GetValue()) {
+				return
+			}
+		}
+	}
+}
+
+// Count returns the count of all elements across all shards.
+func (m *Map[K, V]) Count() int {
+	var count uint
+	for _, shard := range m.shards {
+		count += shard.Count()
+	}
+
+	return int(count)
+}
+
+// All returns a flat map of all keys and values across all shards.
+func (m *Map[K, V]) All() map[K]V {
+	allData := make(map[K]V)
+
+	m.Range(func(key K, value V) bool {
+		allData[key] = value
+
+		return true
+	})
+
+	return allData
+}
+
+// Clear clears all data across all shards.
+func (m *Map[K, V]) Clear() {
+	for _, shard := range m.shards {
+		shard.Clear()
+	}
+}
+
+// Get returns the value for given key or a shardedmap.KeyError wrapping shardedmap.ErrNotFound.
+func (m *Map[K, V]) Get(key K) (V, error) {
+	val, ok := m.getShard(key).Get(key)
+	if !ok {
+		return val, &shardedmap.KeyError{Key: fmt.Sprint(key), Err: shardedmap.ErrNotFound}
+	}
+
+	return val, nil
+}
+
+// MustGet returns the value for a given key or the zero value of V.
+func (m *Map[K, V]) MustGet(key K) V {
+	val, _ := m.getShard(key).Get(key)
+
+	return val
+}
+
+func (m *Map[K, V]) Set(key K, value V) {
+	m.getShard(key).Set(key, value)
+}
+
+func (m *Map[K, V]) Has(key K) bool {
+	return m.getShard(key).Has(key)
+}
+
+func (m *Map[K, V]) Remove(key K) {
+	m.getShard(key).Remove(key)
+}
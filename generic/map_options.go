@@ -0,0 +1,18 @@
+package generic
+
+// MapOption defines an option that can be set in Map constructor.
+type MapOption[K comparable, V any] func(m *Map[K, V])
+
+// WithShardCount specifies the number of shards.
+func WithShardCount[K comparable, V any](count int) MapOption[K, V] {
+	return func(m *Map[K, V]) {
+		m.shardCount = uint(count)
+	}
+}
+
+// WithCustomShardProvider specifies the shard provider function to use.
+func WithCustomShardProvider[K comparable, V any](provider ShardProviderFunc[K, V]) MapOption[K, V] {
+	return func(m *Map[K, V]) {
+		m.shardProviderFunc = provider
+	}
+}
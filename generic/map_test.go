@@ -0,0 +1,98 @@
+package generic_test
+
+import (
+	"github.com/dtomasi/shardedmap"
+	"github.com/dtomasi/shardedmap/generic"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestStringMap(t *testing.T) {
+	m := generic.NewStringMap[int](generic.WithShardCount[string, int](4))
+
+	for i := 0; i < 100; i++ {
+		m.Set(strconv.Itoa(i), i)
+	}
+
+	assert.Equal(t, 100, m.Count())
+
+	v, err := m.Get("42")
+	assert.NoError(t, err)
+	assert.Equal(t, 42, v)
+	assert.Equal(t, 7, m.MustGet("7"))
+
+	_, err = m.Get("____not_existing_key")
+	assert.ErrorIs(t, err, shardedmap.ErrNotFound)
+	assert.Equal(t, 0, m.MustGet("____not_existing_key"))
+
+	m.Remove("42")
+	assert.False(t, m.Has("42"))
+	assert.True(t, m.Has("43"))
+	assert.Len(t, m.All(), 99)
+
+	m.Clear()
+	assert.Equal(t, 0, m.Count())
+}
+
+func TestIntegerKeys(t *testing.T) {
+	m := generic.New[uint64, point](generic.IntegerHasher[uint64], generic.WithShardCount[uint64, point](8))
+
+	for i := uint64(0); i < 64; i++ {
+		m.Set(i, point{int(i), -int(i)})
+	}
+
+	assert.Equal(t, point{3, -3}, m.MustGet(3))
+
+	var sum int
+
+	m.Range(func(key uint64, value point) bool {
+		assert.Equal(t, int(key), value.X)
+		sum += value.X
+
+		return true
+	})
+
+	assert.Equal(t, 63*64/2, sum)
+}
+
+func TestCustomHasherAndProvider(t *testing.T) {
+	var created int
+
+	provider := func() generic.Shard[point, string] {
+		created++
+
+		return generic.NewMutexShard[point, string]()
+	}
+
+	m := generic.New[point, string](
+		func(key point) uint { return generic.IntegerHasher(key.X ^ key.Y) },
+		generic.WithShardCount[point, string](3),
+		generic.WithCustomShardProvider(provider),
+	)
+
+	m.Set(point{1, 2}, "a")
+	m.Set(point{2, 1}, "b") // Same hash, different key
+
+	assert.Equal(t, 3, created)
+	assert.Equal(t, "a", m.MustGet(point{1, 2}))
+	assert.Equal(t, "b", m.MustGet(point{2, 1}))
+	assert.Equal(t, map[point]string{{1, 2}: "a", {2, 1}: "b"}, m.All())
+}
+
+func TestIntegerHasherSpreadsSequentialKeys(t *testing.T) {
+	const shardCount = 8
+
+	counts := make([]int, shardCount)
+	for i := 0; i < 8000; i++ {
+		counts[generic.IntegerHasher(i)%shardCount]++
+	}
+
+	for _, c := range counts {
+		assert.InDelta(t, 1000, c, 150)
+	}
+}
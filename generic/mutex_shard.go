@@ -0,0 +1,80 @@
+package generic
+
+import (
+	"sync"
+)
+
+// NewMutexShard creates a new Shard.
+func NewMutexShard[K comparable, V any]() Shard[K, V] {
+	return &MutexShard[K, V]{ //nolint:exhaustivestruct
+		data: make(map[K]V),
+	}
+}
+
+// MutexShard represents a shard used in Map.
+type MutexShard[K comparable, V any] struct {
+	mu   sync.RWMutex
+	data map[K]V
+}
+
+// All see: Shard.
+func (s *MutexShard[K, V]) All() []Tuple[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tuples := make([]Tuple[K, V], 0, len(s.data))
+	for k, v := range s.data {
+		tuples = append(tuples, NewTuple(k, v))
+	}
+
+	return tuples
+}
+
+// Get see: Shard.
+func (s *MutexShard[K, V]) Get(key K) (V, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.data[key]
+
+	return value, ok
+}
+
+// Set see: Shard.
+func (s *MutexShard[K, V]) Set(key K, value V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+}
+
+// Has see: Shard.
+func (s *MutexShard[K, V]) Has(key K) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.data[key]
+
+	return ok
+}
+
+// Remove see: Shard.
+func (s *MutexShard[K, V]) Remove(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+}
+
+// Count see: Shard.
+func (s *MutexShard[K, V]) Count() uint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return uint(len(s.data))
+}
+
+// Clear see: Shard.
+func (s *MutexShard[K, V]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = make(map[K]V)
+}
@@ -0,0 +1,32 @@
+package generic
+
+// ShardProviderFunc defines a function that is used to create Shards while initializing a Map.
+type ShardProviderFunc[K comparable, V any] func() Shard[K, V]
+
+// Shard defines the interface that can be passed to map.
+//
+// Unlike shardedmap.Shard, a Shard is indexed by the key itself. The hash of a key is only used to
+// select the shard.
+type Shard[K comparable, V any] interface {
+
+	// All returns a copy of all contained data.
+	All() []Tuple[K, V]
+
+	// Get returns a value from Collection.
+	Get(key K) (V, bool)
+
+	// Set sets a value to Collection.
+	Set(key K, value V)
+
+	// Has checks the existence of a key/value in Collection.
+	Has(key K) bool
+
+	// Remove removes an element by key from Collection.
+	Remove(key K)
+
+	// Count returns the count of elements in Collection.
+	Count() uint
+
+	// Clear resets all data in Collection.
+	Clear()
+}
@@ -0,0 +1,23 @@
+package generic
+
+// NewTuple creates a new Tuple.
+func NewTuple[K comparable, V any](key K, value V) Tuple[K, V] {
+	return Tuple[K, V]{
+		key,
+		value,
+	}
+}
+
+// Tuple represents a key value pair.
+type Tuple[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func (t Tuple[K, V]) GetKey() K {
+	return t.key
+}
+
+func (t Tuple[K, V]) GetValue() V {
+	return t.value
+}
@@ -0,0 +1,114 @@
+package shardedmap
+
+import (
+	"context"
+	"time"
+)
+
+// Iterator is a cursor over the entries of a Map. It visits one shard after another and only holds the
+// snapshot of the current shard, so its memory use is bounded by the size of the largest shard.
+//
+//	it := m.Iter(ctx)
+//	for it.Next() {
+//		fmt.Println(it.Key(), it.Value())
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// An Iterator must not be used by multiple goroutines. It is fine to stop iterating at any time.
+type Iterator struct {
+	ctx        context.Context //nolint:containedctx
+	shards     []*shardSlot
+	shardIndex int
+	tuples     []ShardTuple
+	buf        []ShardTuple // buf is the buffer tuples is sliced from, it is reused for every shard.
+	current    ShardTuple
+	err        error
+}
+
+// Iter returns an Iterator over all entries of the map. Each shard is captured when the iterator reaches it,
+// see SnapshotPerShard. The iteration stops once ctx is done. A resize in progress is completed first.
+// Entries that are written after a later resize moved their shard are not visited.
+func (m *Map) Iter(ctx context.Context) *Iterator {
+	shards := m.stableTable().shards
+
+	return &Iterator{ctx: ctx, shards: shards, shardIndex: 0, tuples: nil, buf: nil, current: nil, err: nil}
+}
+
+// Next advances the iterator to the next entry and reports whether there is one.
+func (it *Iterator) Next() bool {
+	it.current = nil
+
+	if it.err != nil {
+		return false
+	}
+
+	if it.err = it.ctx.Err(); it.err != nil {
+		return false
+	}
+
+	for len(it.tuples) == 0 {
+		if it.shardIndex >= len(it.shards) {
+			it.tuples = nil
+
+			return false
+		}
+
+		it.loadShard(it.shards[it.shardIndex])
+		it.shardIndex++
+	}
+
+	it.current, it.tuples = it.tuples[0], it.tuples[1:]
+
+	return true
+}
+
+// loadShard replaces the buffered tuples with the non expired tuples of shard.
+func (it *Iterator) loadShard(shard Shard) {
+	data := shard.Snapshot()
+	now := time.Now().UnixNano()
+
+	// Reuse the buffer of the previous shard. Clearing it drops the references to the tuples of that shard.
+	tuples := it.buf[:0]
+	for i := range it.buf {
+		it.buf[i] = nil
+	}
+
+	if cap(tuples) < len(data) {
+		tuples = make([]ShardTuple, 0, len(data))
+	}
+
+	data.Range(func(_ uint, tuple ShardTuple) bool {
+		if !IsExpired(tuple, now) {
+			tuples = append(tuples, tuple)
+		}
+
+		return true
+	})
+
+	it.buf, it.tuples = tuples, tuples
+}
+
+// Key returns the key of the current entry.
+func (it *Iterator) Key() string {
+	if it.current == nil {
+		return ""
+	}
+
+	return it.current.GetKey()
+}
+
+// Value returns the value of the current entry.
+func (it *Iterator) Value() interface{} {
+	if it.current == nil {
+		return nil
+	}
+
+	return it.current.GetValue()
+}
+
+// Err returns the error of the context if the iteration was stopped because the context is done.
+func (it *Iterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,26 @@
+//go:build go1.23
+
+package shardedmap
+
+import (
+	"context"
+	"iter"
+)
+
+// Entries returns an iterator over all keys and values of the map for use with range.
+// It behaves like Iter. Check ctx.Err() after the loop to find out if the iteration was cancelled.
+//
+//	for key, value := range m.Entries(ctx) {
+//		...
+//	}
+func (m *Map) Entries(ctx context.Context) iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		it := m.Iter(ctx)
+
+		for it.Next() {
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+		}
+	}
+}
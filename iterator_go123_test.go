@@ -0,0 +1,33 @@
+//go:build go1.23
+
+package shardedmap_test
+
+import (
+	"context"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEntries(t *testing.T) {
+	instance := shardedmap.New()
+	for _, k := range []string{"a", "b", "c"} {
+		instance.Set(k, k)
+	}
+
+	data := make(map[string]interface{})
+	for key, value := range instance.Entries(context.Background()) {
+		data[key] = value
+	}
+
+	assert.Equal(t, instance.All(), data)
+
+	var visited int
+	for range instance.Entries(context.Background()) {
+		visited++
+
+		break
+	}
+
+	assert.Equal(t, 1, visited)
+}
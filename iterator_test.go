@@ -0,0 +1,73 @@
+package shardedmap_test
+
+import (
+	"context"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func (s *MapTestSuite) TestIter() {
+	data := make(map[string]interface{}, len(s.testDataSet))
+
+	it := s.instance.Iter(context.Background())
+	for it.Next() {
+		data[it.Key()] = it.Value()
+	}
+
+	s.NoError(it.Err())
+	s.False(it.Next())
+	s.Equal(s.testDataSet, data)
+}
+
+func TestIterEarlyBreakAndCancel(t *testing.T) {
+	instance := shardedmap.New(shardedmap.WithShardCount(4))
+	for _, k := range []string{"a", "b", "c", "d", "e", "f"} {
+		instance.Set(k, k)
+	}
+
+	instance.SetWithTTL("expired", "expired", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	var visited int
+
+	it := instance.Iter(context.Background())
+	for it.Next() {
+		assert.NotEqual(t, "expired", it.Key())
+
+		visited++
+		if visited == 2 {
+			break
+		}
+	}
+
+	assert.Equal(t, 2, visited)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it = instance.Iter(ctx)
+
+	assert.True(t, it.Next())
+	cancel()
+	assert.False(t, it.Next())
+	assert.ErrorIs(t, it.Err(), context.Canceled)
+	assert.Equal(t, "", it.Key())
+	assert.Nil(t, it.Value())
+}
+
+func TestIterSeesWritesToShardsNotVisitedYet(t *testing.T) {
+	instance := shardedmap.New(shardedmap.WithShardCount(1))
+	instance.Set("a", 1)
+
+	it := instance.Iter(context.Background())
+
+	// The only shard is loaded on the first call of Next
+	instance.Set("b", 2)
+
+	var keys []string
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+
+	assert.ElementsMatch(t, []string{"a", "b"}, keys)
+}
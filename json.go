@@ -0,0 +1,80 @@
+package shardedmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ValueDecoder decodes the next JSON value from dec. It is used by Map.DecodeJSON and Map.UnmarshalJSON.
+type ValueDecoder func(dec *json.Decoder) (interface{}, error)
+
+// DecodeAny decodes values like json.Unmarshal into an interface{}: numbers become float64 and objects
+// map[string]interface{}. It is the default ValueDecoder.
+func DecodeAny(dec *json.Decoder) (interface{}, error) {
+	var value interface{}
+	err := dec.Decode(&value)
+
+	return value, err
+}
+
+// DecodeAs returns a ValueDecoder that decodes every value into a V.
+//
+//	m := shardedmap.New(shardedmap.WithValueDecoder(shardedmap.DecodeAs[User]()))
+func DecodeAs[V any]() ValueDecoder {
+	return func(dec *json.Decoder) (interface{}, error) {
+		var value V
+		err := dec.Decode(&value)
+
+		return value, err
+	}
+}
+
+// DecodeJSON reads a JSON object from r and sets its members. Values are decoded with the ValueDecoder set by
+// WithValueDecoder. The object is read member by member, so large documents are never held in memory.
+// If an error occurs, the members before it have already been set. A JSON null sets nothing.
+func (m *Map) DecodeJSON(r io.Reader) error {
+	dec := json.NewDecoder(r)
+
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if token == nil {
+		return nil
+	}
+
+	if token != json.Delim('{') {
+		return &json.UnmarshalTypeError{ //nolint:exhaustivestruct
+			Value:  fmt.Sprint(token),
+			Type:   reflect.TypeOf(m),
+			Offset: dec.InputOffset(),
+		}
+	}
+
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		// Inside an object, the decoder only returns strings as keys
+		key, _ := token.(string)
+
+		value, err := m.valueDecoder(dec)
+		if err != nil {
+			return newKeyError(key, err)
+		}
+
+		if err := m.Set(key, value); err != nil {
+			return err
+		}
+	}
+
+	// Consume the closing brace
+	_, err = dec.Token()
+
+	return err
+}
@@ -0,0 +1,78 @@
+package shardedmap_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalJSONWithValueDecoder(t *testing.T) {
+	source := shardedmap.New()
+	assert.NoError(t, source.Set("a", persistedValue{Name: "a", Count: 1}))
+	assert.NoError(t, source.Set("b", persistedValue{Name: "b", Count: 2}))
+
+	data, err := json.Marshal(source)
+	assert.NoError(t, err)
+
+	target := shardedmap.New(shardedmap.WithValueDecoder(shardedmap.DecodeAs[persistedValue]()))
+	assert.NoError(t, json.Unmarshal(data, target))
+
+	assert.Equal(t, persistedValue{Name: "a", Count: 1}, target.MustGet("a"))
+	assert.Equal(t, persistedValue{Name: "b", Count: 2}, target.MustGet("b"))
+
+	// Without a decoder, values are generic maps
+	generic := shardedmap.New()
+	assert.NoError(t, json.Unmarshal(data, generic))
+	assert.Equal(t, map[string]interface{}{"Name": "a", "Count": 1.0}, generic.MustGet("a"))
+}
+
+func TestUnmarshalJSONRejectsInvalidDocuments(t *testing.T) {
+	instance := shardedmap.New(shardedmap.WithValueDecoder(shardedmap.DecodeAs[int]()))
+
+	assert.Error(t, instance.UnmarshalJSON([]byte(`{"a": 1, "b": `)))
+	assert.Error(t, instance.UnmarshalJSON([]byte(`[1, 2]`)))
+	assert.Equal(t, 0, instance.Count())
+
+	err := instance.UnmarshalJSON([]byte(`{"a": 1, "b": "two"}`))
+
+	var keyErr *shardedmap.KeyError
+	if assert.ErrorAs(t, err, &keyErr) {
+		assert.Equal(t, "b", keyErr.Key)
+	}
+
+	assert.NoError(t, instance.UnmarshalJSON([]byte(`null`)))
+}
+
+func TestDecodeJSONStreamsMembers(t *testing.T) {
+	const members = 10000
+
+	// The document is produced while it is decoded
+	reader, writer := io.Pipe()
+
+	go func() {
+		_, _ = io.WriteString(writer, "{")
+
+		for i := 0; i < members; i++ {
+			if i > 0 {
+				_, _ = io.WriteString(writer, ",")
+			}
+
+			_, _ = fmt.Fprintf(writer, `"%d": %d`, i, i)
+		}
+
+		_, _ = io.WriteString(writer, "}")
+		_ = writer.Close()
+	}()
+
+	instance := shardedmap.New(shardedmap.WithValueDecoder(shardedmap.DecodeAs[int]()))
+	assert.NoError(t, instance.DecodeJSON(reader))
+
+	assert.Equal(t, members, instance.Count())
+	assert.Equal(t, 1234, instance.MustGet("1234"))
+
+	assert.Error(t, instance.DecodeJSON(strings.NewReader(`{"a": 1`)))
+}
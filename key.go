@@ -1,4 +1,32 @@
 package shardedmap
 
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"hash/maphash"
+)
+
 // KeyHashFunc defines a function that is used create a hash from a given key.
 type KeyHashFunc func(key string) uint
+
+// SeededKeyHashFunc defines a function that creates a hash from a given key and a seed.
+// Keys that collide for one seed do not collide for others, so a secret seed protects a Map from hash flooding.
+type SeededKeyHashFunc func(seed uint64, key string) uint
+
+// WithSeed returns a KeyHashFunc that hashes keys with f and seed.
+func (f SeededKeyHashFunc) WithSeed(seed uint64) KeyHashFunc {
+	return func(key string) uint {
+		return f(seed, key)
+	}
+}
+
+// randomSeed returns a random seed from crypto/rand. If no secure random numbers are available, it falls back to
+// the random seeds of hash/maphash.
+func randomSeed() uint64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return new(maphash.Hash).Sum64()
+	}
+
+	return binary.LittleEndian.Uint64(b[:])
+}
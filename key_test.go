@@ -0,0 +1,117 @@
+package shardedmap_test
+
+import (
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+func TestHashXXHash64(t *testing.T) {
+	for key, expected := range map[string]uint64{
+		"":    0xef46db3751d8e999,
+		"a":   0xd24ec4f1a98c6e5b,
+		"abc": 0x44bc2cf5ad770999,
+		"Nobody inspects the spammish repetition": 0xfbcea83c8a378bf1,
+	} {
+		assert.Equal(t, uint(expected), shardedmap.HashXXHash64(key), key)
+	}
+
+	assert.NotEqual(t, shardedmap.HashXXHash64Seeded(1, "abc"), shardedmap.HashXXHash64("abc"))
+}
+
+func TestHashCRC32C(t *testing.T) {
+	assert.Equal(t, uint(0), shardedmap.HashCRC32C(""))
+	assert.Equal(t, uint(0xe3069283), shardedmap.HashCRC32C("123456789"))
+}
+
+func TestNewHashMaphash(t *testing.T) {
+	hash := shardedmap.NewHashMaphash()
+
+	assert.Equal(t, hash("key"), hash("key"))
+	assert.NotEqual(t, hash("key"), hash("other"))
+	assert.NotEqual(t, shardedmap.NewHashMaphash()("key"), hash("key"))
+}
+
+func TestSeededKeyHashFunc(t *testing.T) {
+	seeded := shardedmap.SeededKeyHashFunc(shardedmap.HashXXHash64Seeded)
+
+	assert.Equal(t, shardedmap.HashXXHash64Seeded(42, "key"), seeded.WithSeed(42)("key"))
+	assert.NotEqual(t, seeded.WithSeed(1)("key"), seeded.WithSeed(2)("key"))
+}
+
+func TestMapRunSuiteWithKeyHashFuncs(t *testing.T) {
+	for _, keyHashFunc := range []shardedmap.KeyHashFunc{
+		shardedmap.HashXXHash64,
+		shardedmap.HashCRC32C,
+		shardedmap.NewHashMaphash(),
+		shardedmap.SeededKeyHashFunc(shardedmap.HashXXHash64Seeded).WithSeed(42),
+	} {
+		suite.Run(t, NewMapTestSuite(
+			shardedmap.New(shardedmap.WithShardCount(8), shardedmap.WithCustomKeyHashFunc(keyHashFunc)),
+		))
+	}
+}
+
+func TestKeyHashFuncsSpreadSequentialKeys(t *testing.T) {
+	const shardCount, keys = 16, 16000
+
+	for name, keyHashFunc := range map[string]shardedmap.KeyHashFunc{
+		"xxhash64": shardedmap.HashXXHash64,
+		"crc32c":   shardedmap.HashCRC32C,
+		"maphash":  shardedmap.NewHashMaphash(),
+	} {
+		perShard := make([]int, shardCount)
+
+		for i := 0; i < keys; i++ {
+			perShard[keyHashFunc(fmt.Sprint("user:", i))%shardCount]++
+		}
+
+		for _, count := range perShard {
+			assert.InDelta(t, keys/shardCount, count, keys/shardCount*0.2, name)
+		}
+	}
+}
+
+func TestWithHashSeed(t *testing.T) {
+	instance := shardedmap.New(shardedmap.WithHashSeed(42))
+
+	seed, ok := instance.HashSeed()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(42), seed)
+
+	suite.Run(t, NewMapTestSuite(instance))
+
+	_, ok = shardedmap.New().HashSeed()
+	assert.False(t, ok)
+}
+
+func TestWithRandomHashSeed(t *testing.T) {
+	option := shardedmap.WithRandomHashSeed()
+	first, _ := shardedmap.New(option).HashSeed()
+	second, _ := shardedmap.New(option).HashSeed()
+
+	assert.NotEqual(t, first, second)
+
+	suite.Run(t, NewMapTestSuite(shardedmap.New(option)))
+}
+
+func TestHashSeedReplacesKeyHashFunc(t *testing.T) {
+	var calls []uint64
+
+	// Every key collides without the seed
+	instance := shardedmap.New(
+		shardedmap.WithCustomKeyHashFunc(func(string) uint { return 7 }),
+		shardedmap.WithCustomSeededKeyHashFunc(func(seed uint64, key string) uint {
+			calls = append(calls, seed)
+
+			return shardedmap.HashXXHash64Seeded(seed, key)
+		}),
+		shardedmap.WithHashSeed(3),
+	)
+
+	assert.NoError(t, instance.Set("a", 1))
+	assert.Equal(t, 1, instance.MustGet("a"))
+	assert.Equal(t, []uint64{3, 3}, calls)
+}
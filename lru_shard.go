@@ -0,0 +1,277 @@
+package shardedmap
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// NewLRUShard creates a new LRUShard.
+func NewLRUShard() Shard {
+	return &LRUShard{ //nolint:exhaustivestruct
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// LRUShard represents a shard used in Map that evicts the least recently used entry once it holds
+// more entries than configured by ShardConfig.MaxEntries. Get marks an entry as recently used.
+type LRUShard struct {
+	mu         sync.Mutex
+	items      map[string]*list.Element
+	order      *list.List
+	maxEntries uint
+	evictions  uint64
+	onEvict    EvictionHandler
+}
+
+type lruItem struct {
+	keyHash uint
+	tuple   ShardTuple
+}
+
+// Configure see: interfaces.ConfigurableShard.
+func (s *LRUShard) Configure(cfg ShardConfig) {
+	s.mu.Lock()
+
+	s.maxEntries = cfg.MaxEntries
+	s.onEvict = cfg.OnEvict
+	evicted := s.evict()
+
+	s.mu.Unlock()
+
+	s.onEvict.notify(EvictionReasonCapacity, evicted...)
+}
+
+// Evictions see: interfaces.EvictionCounter.
+func (s *LRUShard) Evictions() uint64 {
+	return atomic.LoadUint64(&s.evictions)
+}
+
+// Snapshot see: interfaces.Shard. It returns a copy of the data.
+func (s *LRUShard) Snapshot() ShardDataMap {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := make(ShardDataMap, len(s.items))
+	for e := s.order.Front(); e != nil; e = e.Next() {
+		item := e.Value.(lruItem) // nolint:forcetypeassert
+		data.Store(item.keyHash, item.tuple)
+	}
+
+	return data
+}
+
+// Get see: interfaces.Shard.
+func (s *LRUShard) Get(keyHash uint, key string) (interface{}, error) {
+	tuple, err := s.GetTuple(keyHash, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return tuple.GetValue(), nil
+}
+
+// GetTuple see: interfaces.TupleGetter. Like Get, it marks the entry as recently used.
+func (s *LRUShard) GetTuple(keyHash uint, key string) (ShardTuple, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tuple ShardTuple
+
+	e, ok := s.items[key]
+	if ok {
+		tuple = e.Value.(lruItem).tuple // nolint:forcetypeassert
+	}
+
+	if err := lookupError(key, tuple, ok); err != nil {
+		return nil, err
+	}
+
+	s.order.MoveToFront(e)
+
+	return tuple, nil
+}
+
+// Set see: interfaces.Shard. It never fails, the least recently used entry is evicted instead.
+func (s *LRUShard) Set(keyHash uint, tuple ShardTuple) error {
+	s.mu.Lock()
+
+	item := lruItem{keyHash: keyHash, tuple: tuple}
+
+	if e, ok := s.items[tuple.GetKey()]; ok {
+		previous := e.Value.(lruItem).tuple // nolint:forcetypeassert
+		e.Value = item
+		s.order.MoveToFront(e)
+		s.mu.Unlock()
+
+		if isExpiredNow(previous) {
+			s.onEvict.notify(EvictionReasonExpired, previous)
+		}
+
+		return nil
+	}
+
+	s.items[tuple.GetKey()] = s.order.PushFront(item)
+	evicted := s.evict()
+
+	s.mu.Unlock()
+
+	s.onEvict.notify(EvictionReasonCapacity, evicted...)
+
+	return nil
+}
+
+// Has see: interfaces.Shard.
+func (s *LRUShard) Has(keyHash uint, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.items[key]
+
+	return ok && !isExpiredNow(e.Value.(lruItem).tuple) // nolint:forcetypeassert
+}
+
+// Remove see: interfaces.Shard.
+func (s *LRUShard) Remove(keyHash uint, key string) {
+	s.mu.Lock()
+
+	e, ok := s.items[key]
+	if ok {
+		s.removeElement(e)
+	}
+
+	s.mu.Unlock()
+
+	if ok {
+		s.onEvict.notify(EvictionReasonRemoved, e.Value.(lruItem).tuple) // nolint:forcetypeassert
+	}
+}
+
+// Count see: interfaces.Shard.
+func (s *LRUShard) Count() uint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return uint(len(s.items))
+}
+
+// RemoveExpired see: interfaces.Shard.
+func (s *LRUShard) RemoveExpired(now int64) uint {
+	s.mu.Lock()
+
+	var expired []ShardTuple
+
+	for e := s.order.Front(); e != nil; {
+		next := e.Next()
+
+		if tuple := e.Value.(lruItem).tuple; IsExpired(tuple, now) { // nolint:forcetypeassert
+			s.removeElement(e)
+			expired = append(expired, tuple)
+		}
+
+		e = next
+	}
+
+	s.mu.Unlock()
+
+	s.onEvict.notify(EvictionReasonExpired, expired...)
+
+	return uint(len(expired))
+}
+
+// Clear see: interfaces.Shard.
+func (s *LRUShard) Clear() {
+	s.mu.Lock()
+
+	cleared := s.order
+	s.items = make(map[string]*list.Element)
+	s.order = list.New()
+
+	s.mu.Unlock()
+
+	if s.onEvict != nil {
+		for e := cleared.Front(); e != nil; e = e.Next() {
+			s.onEvict.notify(EvictionReasonCleared, e.Value.(lruItem).tuple) // nolint:forcetypeassert
+		}
+	}
+}
+
+func (s *LRUShard) removeElement(e *list.Element) {
+	s.order.Remove(e)
+	delete(s.items, e.Value.(lruItem).tuple.GetKey()) // nolint:forcetypeassert
+}
+
+// evict removes the least recently used entries until the shard is within its capacity and returns them.
+func (s *LRUShard) evict() []ShardTuple {
+	if s.maxEntries == 0 {
+		return nil
+	}
+
+	var evicted []ShardTuple
+
+	for uint(len(s.items)) > s.maxEntries {
+		e := s.order.Back()
+		s.removeElement(e)
+		atomic.AddUint64(&s.evictions, 1)
+
+		evicted = append(evicted, e.Value.(lruItem).tuple) // nolint:forcetypeassert
+	}
+
+	return evicted
+}
+
+// Compute see: interfaces.Shard.
+func (s *LRUShard) Compute(keyHash uint, key string, fn ComputeFunc) (ShardTuple, error) {
+	var (
+		tuple    ShardTuple
+		dropped  []ShardTuple
+		reason   EvictionReason
+		eviction []ShardTuple
+	)
+
+	func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		var current ShardTuple
+
+		e, exists := s.items[key]
+		if exists {
+			current = e.Value.(lruItem).tuple // nolint:forcetypeassert
+			if isExpiredNow(current) {
+				dropped, reason = []ShardTuple{current}, EvictionReasonExpired
+				current = nil
+			}
+		}
+
+		next, write := fn(current)
+
+		switch {
+		case !write:
+			dropped = nil
+			tuple = current
+		case next != nil && exists:
+			e.Value = lruItem{keyHash: keyHash, tuple: next}
+			s.order.MoveToFront(e)
+
+			tuple = next
+		case next != nil:
+			s.items[key] = s.order.PushFront(lruItem{keyHash: keyHash, tuple: next})
+			eviction = s.evict()
+
+			tuple = next
+		case exists:
+			s.removeElement(e)
+
+			if dropped == nil {
+				dropped, reason = []ShardTuple{current}, EvictionReasonRemoved
+			}
+		}
+	}()
+
+	s.onEvict.notify(reason, dropped...)
+	s.onEvict.notify(EvictionReasonCapacity, eviction...)
+
+	return tuple, nil
+}
@@ -0,0 +1,55 @@
+package shardedmap_test
+
+import (
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+func TestLRUShardTestsInSuite(t *testing.T) {
+	suite.Run(t, NewShardTestSuite(shardedmap.NewLRUShard()))
+}
+
+func TestLRUShardEvictsLeastRecentlyUsed(t *testing.T) {
+	shard := shardedmap.NewLRUShard()
+	shard.(shardedmap.ConfigurableShard).Configure(shardedmap.ShardConfig{MaxEntries: 3})
+
+	for _, k := range []string{"a", "b", "c"} {
+		shard.Set(shardedmap.HashFnv1a64(k), shardedmap.NewTuple(k, k))
+	}
+
+	// Touch "a" so "b" becomes the least recently used entry
+	_, err := shard.Get(shardedmap.HashFnv1a64("a"), "a")
+	assert.NoError(t, err)
+
+	shard.Set(shardedmap.HashFnv1a64("d"), shardedmap.NewTuple("d", "d"))
+
+	assert.Equal(t, uint(3), shard.Count())
+	assert.False(t, shard.Has(shardedmap.HashFnv1a64("b"), "b"))
+
+	for _, k := range []string{"a", "c", "d"} {
+		assert.True(t, shard.Has(shardedmap.HashFnv1a64(k), k))
+	}
+
+	assert.Equal(t, uint64(1), shard.(shardedmap.EvictionCounter).Evictions())
+}
+
+func TestMapWithMaxEntries(t *testing.T) {
+	instance := shardedmap.New(
+		shardedmap.WithShardCount(4),
+		shardedmap.WithCustomShardProvider(shardedmap.NewLRUShard),
+		shardedmap.WithMaxEntries(10),
+	)
+
+	for i := 0; i < 100; i++ {
+		instance.Set(fmt.Sprintf("key-%d", i), i)
+	}
+
+	assert.LessOrEqual(t, instance.Count(), 10)
+	assert.Equal(t, uint64(100-instance.Count()), instance.Evictions())
+
+	// The most recently added entry is never evicted
+	assert.Equal(t, 99, instance.MustGet("key-99"))
+}
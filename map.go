@@ -1,22 +1,68 @@
 package shardedmap
 
 import (
+	"bytes"
 	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // DefaultShardCount allows overwriting package default for New().
 var (
-	DefaultShardCount        uint              = 8             //nolint:gochecknoglobals
-	DefaultShardProviderFunc ShardProviderFunc = NewMutexShard //nolint:gochecknoglobals
-	DefaultKeyHashFunc       KeyHashFunc       = HashFnv1a64   //nolint:gochecknoglobals
+	DefaultShardCount        uint              = 8                //nolint:gochecknoglobals
+	DefaultShardProviderFunc ShardProviderFunc = NewMutexShard    //nolint:gochecknoglobals
+	DefaultKeyHashFunc       KeyHashFunc       = HashFnv1a64      //nolint:gochecknoglobals
+	DefaultShardSelector     ShardSelector     = ModuloSelector{} //nolint:gochecknoglobals
+	DefaultOptimisticRetries int               = 10               //nolint:gochecknoglobals
+	DefaultWatchBufferSize   int               = 64               //nolint:gochecknoglobals
 )
 
 // Map represents the sharded map.
 type Map struct {
-	shards            []Shard
+	table             atomic.Pointer[shardTable]
 	shardCount        uint
 	shardProviderFunc ShardProviderFunc
 	keyHashFunc       KeyHashFunc
+	seededHashFunc    SeededKeyHashFunc
+	hashSeed          uint64
+	seeded            bool
+	shardSelector     ShardSelector
+	powerOfTwo        bool
+	valueCodec        ValueCodec
+	valueDecoder      ValueDecoder
+	wal               *wal
+	walSyncPolicy     WALSyncPolicy
+	walSyncInterval   time.Duration
+	walCompactSize    int64
+	maxEntries        uint
+	defaultTTL        time.Duration
+	janitorInterval   time.Duration
+	onEvict           EvictionFunc
+	evictionQueueSize int
+	evictionQueue     chan evictionEvent
+	evictionDone      chan struct{}
+	evictionBusy      atomic.Bool
+	evictionDrainer   atomic.Uint64
+	evictionBacklog   []evictionEvent
+	evictionSenders   sync.WaitGroup
+	evictionMu        sync.RWMutex
+	janitorStop       chan struct{}
+	janitorWG         sync.WaitGroup
+	closeOnce         sync.Once
+	closeErr          error
+	resizeMu          sync.Mutex
+	resizeWG          sync.WaitGroup
+	growThreshold     uint
+	growMaxShardCount uint
+	growing           atomic.Bool
+	retiredEvictions  atomic.Uint64
+	stats             bool
+	batchWorkers      int
+	optimisticRetries int
+	watchers          watchHub
+	waiting           atomic.Int32
+	retiredStats      *shardStats
 }
 
 // New creates a new sharded map.
@@ -28,7 +74,17 @@ func New(opts ...MapOption) *Map {
 		opt(m)
 	}
 
+	if m.seeded {
+		m.keyHashFunc = m.seededHashFunc.WithSeed(m.hashSeed)
+	}
+
+	if m.stats {
+		m.retiredStats = &shardStats{} //nolint:exhaustivestruct
+	}
+
+	m.startEvictionQueue()
 	m.initShards()
+	m.startJanitors()
 
 	return m
 }
@@ -37,93 +93,281 @@ func (m *Map) applyDefaults() {
 	m.shardCount = DefaultShardCount
 	m.shardProviderFunc = DefaultShardProviderFunc
 	m.keyHashFunc = DefaultKeyHashFunc
+	m.seededHashFunc = HashXXHash64Seeded
+	m.shardSelector = DefaultShardSelector
+	m.valueCodec = GobCodec{}
+	m.valueDecoder = DecodeAny
+	m.optimisticRetries = DefaultOptimisticRetries
+}
+
+// shardSlot holds a shard together with the state the Map keeps per shard.
+type shardSlot struct {
+	Shard
+
+	// gate is held for reading while the shard is modified and for writing while a consistent snapshot
+	// of the whole Map is taken or the shard is evacuated.
+	gate sync.RWMutex
+
+	// evacuated is set while holding gate once the entries were moved to the next table, see Resize.
+	evacuated atomic.Bool
+
+	// stats holds the counters of the shard, it is nil unless the Map was created WithStats.
+	stats *shardStats
+
+	// waiters holds the goroutines waiting for keys of the shard, see WaitFor.
+	waiters shardWaiters
+
+	// versions is the version of the last tuple stamped for the shard, see stamp.
+	versions atomic.Uint64
+
+	// While deferring is set, the evictions of the shard are buffered in deferred, see Tx.release. deferring is
+	// only set while holding gate exclusively. Every modification of the shard holds gate, so both fields are
+	// only accessed by the goroutine that holds it exclusively.
+	deferring bool
+	deferred  []evictionEvent
+}
+
+// stamp returns tuple with the next version of the shard. Writers call it from a ComputeFunc, or while holding gate
+// exclusively, so the versions of a key increase in the order its tuples are stored. Tuples of other types than
+// Tuple have no version and are returned unchanged.
+func (s *shardSlot) stamp(tuple ShardTuple) ShardTuple {
+	t, ok := tuple.(Tuple)
+	if !ok {
+		return tuple
+	}
+
+	t.version = s.versions.Add(1)
+
+	return t
+}
+
+// observeVersions raises the version of the shard to at least version, so tuples moved from another shard keep
+// a lower version than the tuples stamped afterwards.
+func (s *shardSlot) observeVersions(version uint64) {
+	for {
+		current := s.versions.Load()
+		if current >= version || s.versions.CompareAndSwap(current, version) {
+			return
+		}
+	}
 }
 
 func (m *Map) initShards() {
-	m.shards = make([]Shard, m.shardCount)
+	m.table.Store(m.newTable(m.shardCount))
+}
 
-	for j := 0; j < int(m.shardCount); j++ {
-		m.shards[j] = m.shardProviderFunc()
+// initEmpty initializes a Map that was not created by New with the defaults, so it can be decoded into.
+func (m *Map) initEmpty() {
+	if m.table.Load() == nil && m.shardCount == 0 {
+		m.applyDefaults()
+		m.initShards()
 	}
 }
 
+// shardConfig returns the configuration for the shard at index in a table of count shards.
+func (m *Map) shardConfig(index, count uint) ShardConfig {
+	cfg := ShardConfig{ //nolint:exhaustivestruct
+		OnEvict: m.evictionHandler(),
+	}
+
+	// Split the capacity evenly and hand out the remainder to the first shards.
+	// Every shard can hold at least one entry.
+	if m.maxEntries > 0 {
+		cfg.MaxEntries = m.maxEntries / count
+		if index < m.maxEntries%count {
+			cfg.MaxEntries++
+		}
+
+		if cfg.MaxEntries == 0 {
+			cfg.MaxEntries = 1
+		}
+	}
+
+	return cfg
+}
+
+// startJanitors starts one goroutine per shard that removes expired entries every janitorInterval.
+// If the Map is resized, janitor j sweeps the shards j, j+n, j+2n, ... where n is the initial shard count.
+func (m *Map) startJanitors() {
+	if m.janitorInterval <= 0 {
+		return
+	}
+
+	m.janitorStop = make(chan struct{})
+
+	for j := uint(0); j < m.shardCount; j++ {
+		m.janitorWG.Add(1)
+
+		go func(first uint) {
+			defer m.janitorWG.Done()
+
+			ticker := time.NewTicker(m.janitorInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case now := <-ticker.C:
+					m.removeExpired(first, now.UnixNano())
+				case <-m.janitorStop:
+					return
+				}
+			}
+		}(j)
+	}
+}
+
+// removeExpired removes the expired entries of every shardCount-th shard starting at first. While the Map is
+// resized, shards of both tables are visited.
+func (m *Map) removeExpired(first uint, now int64) {
+	for t := m.table.Load(); t != nil; t = t.next.Load() {
+		for index := first; index < t.count; index += m.shardCount {
+			shard := t.shards[index]
+
+			shard.gate.RLock()
+			if !shard.evacuated.Load() {
+				shard.RemoveExpired(now)
+			}
+			shard.gate.RUnlock()
+		}
+	}
+}
+
+// Close stops the janitor goroutines, waits for a resize in progress, closes the write-ahead log, delivers all
+// queued evictions and ends all subscriptions of Watch. The Map stays usable, expired entries are then only hidden
+// from Get and Has and evictions are delivered synchronously. Writes to a Map created by Open fail with ErrClosed.
+// Close is safe to call multiple times, later calls return the result of the first call.
+func (m *Map) Close() error {
+	m.closeOnce.Do(func() {
+		if m.janitorStop != nil {
+			close(m.janitorStop)
+			m.janitorWG.Wait()
+		}
+
+		m.resizeWG.Wait()
+
+		if m.wal != nil {
+			// With all gates locked, no writer is between checking the log and appending to it
+			t := m.lockTable()
+			m.closeErr = m.wal.close()
+			unlockTable(t)
+		}
+
+		m.stopEvictionQueue()
+		m.watchers.close()
+	})
+
+	return m.closeErr
+}
+
+func (m *Map) expiryFromTTL(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+
+	return time.Now().Add(ttl).UnixNano()
+}
+
+// newTuple creates a tuple for key that expires after the default TTL.
+func (m *Map) newTuple(key string, value interface{}) ShardTuple {
+	return NewTupleWithExpiry(key, value, m.expiryFromTTL(m.defaultTTL))
+}
+
+// HashSeed returns the seed keys are hashed with and whether the Map uses one, see WithHashSeed.
+// A Map created with the same seed and options distributes keys the same way.
+func (m *Map) HashSeed() (uint64, bool) {
+	return m.hashSeed, m.seeded
+}
+
 func (m *Map) getKeyHash(key string) uint {
 	return m.keyHashFunc(key)
 }
 
-func (m *Map) calculateShardIndex(keyHash uint) uint {
-	return keyHash % m.shardCount
+// getKeyHashAndShardFromKey returns the hash of key and the shard to read it from, see readShard.
+func (m *Map) getKeyHashAndShardFromKey(key string) (keyHash uint, shard *shardSlot) {
+	keyHash = m.getKeyHash(key)
+	shard = m.readShard(keyHash)
+
+	return
 }
 
-func (m *Map) getKeyHashAndShardFromKey(key string) (keyHash uint, shard Shard) {
+// getKeyHashAndLockShardFromKey returns the hash of key and the shard to modify it in, see lockShard.
+func (m *Map) getKeyHashAndLockShardFromKey(key string) (keyHash uint, shard *shardSlot) {
 	keyHash = m.getKeyHash(key)
-	shard = m.shards[m.calculateShardIndex(keyHash)]
+	shard = m.lockShard(keyHash)
 
 	return
 }
 
+// RangeWithCallback calls cb for every entry of a per shard snapshot. If cb returns a value other than nil,
+// it replaces the value of the entry.
 func (m *Map) RangeWithCallback(cb func(key string, value interface{}) interface{}) {
-	for _, shard := range m.shards {
-		for keyHash, t := range shard.All() {
+	now := time.Now().UnixNano()
+
+	for _, shard := range m.stableTable().shards {
+		shard.Snapshot().Range(func(keyHash uint, t ShardTuple) bool {
+			if IsExpired(t, now) {
+				return true
+			}
+
 			newVal := cb(t.GetKey(), t.GetValue())
 			if newVal != nil {
-				shard.Set(keyHash, NewTuple(t.GetKey(), newVal))
+				// The key existed in the snapshot. If it was removed meanwhile and the shard is full now,
+				// the new value is dropped like a write that happened before the removal.
+				_ = m.storeTuple(NewTupleWithExpiry(t.GetKey(), newVal, t.GetExpiry()))
 			}
-		}
+
+			return true
+		})
 	}
 }
 
-// Range allows iterating over a buffered data set.
+// Range allows iterating over a buffered data set. The data set is a snapshot in SnapshotPerShard mode.
 func (m *Map) Range() <-chan ShardTuple {
-	// The result channel which we return
-	resChan := make(chan ShardTuple, m.Count())
+	snapshot := m.Snapshot(SnapshotPerShard)
+
+	// The channel is sized from the snapshot, so filling it never blocks.
+	resChan := make(chan ShardTuple, snapshot.Count())
 	defer close(resChan)
 
-	// A channel to which every shard if its is done
-	shardDoneChan := make(chan bool, m.shardCount)
-	defer close(shardDoneChan)
+	snapshot.rangeTuples(func(t ShardTuple) bool {
+		resChan <- t
 
-	// loop over shards
-	for _, s := range m.shards {
-		// Fetch all data in a separate goroutine
-		go func(shard Shard, doneChan chan bool) {
-			// Push results to resChan
-			for _, v := range shard.All() {
-				resChan <- v
-			}
-			// Notify that we are done here
-			doneChan <- true
-		}(s, shardDoneChan)
-	}
+		return true
+	})
 
-	var shardsDoneCount uint
+	return resChan
+}
 
-	// Wait for all to finish
-	for { // nolint:gosimple
-		select {
-		case <-shardDoneChan:
-			shardsDoneCount++
-			if shardsDoneCount == m.shardCount {
-				return resChan
-			}
+// Evictions returns the number of entries evicted because of capacity limits across all shards.
+func (m *Map) Evictions() uint64 {
+	shards := m.stableTable().shards
+	evictions := m.retiredEvictions.Load()
+
+	for _, shard := range shards {
+		if counter, ok := shard.Shard.(EvictionCounter); ok {
+			evictions += counter.Evictions()
 		}
 	}
+
+	return evictions
 }
 
 // Count returns the count of all elements across all shards.
 func (m *Map) Count() int {
+	t := m.stableTable()
+
 	// The result channel which we return
 	countChan := make(chan uint)
 	defer close(countChan)
 
 	// A channel to which every shard if its is done
-	shardDoneChan := make(chan bool, m.shardCount)
+	shardDoneChan := make(chan bool, t.count)
 	defer close(shardDoneChan)
 
 	// loop over shards
-	for _, s := range m.shards {
+	for _, s := range t.shards {
 		// Fetch all data in a separate goroutine
-		go func(shard Shard, doneChan chan bool) {
+		go func(shard *shardSlot, doneChan chan bool) {
 			// Push results to resChan
 			countChan <- shard.Count()
 			// Notify that we are done here
@@ -144,35 +388,63 @@ func (m *Map) Count() int {
 
 		case <-shardDoneChan:
 			shardsDoneCount++
-			if shardsDoneCount == m.shardCount {
+			if shardsDoneCount == t.count {
 				return int(totalCount)
 			}
 		}
 	}
 }
 
-// All returns a flat map of all keys and values across all shards.
+// All returns a flat map of all keys and values across all shards taken in SnapshotPerShard mode.
 func (m *Map) All() map[string]interface{} {
-	allData := make(map[string]interface{})
+	return m.Snapshot(SnapshotPerShard).All()
+}
 
-	for valTuple := range m.Range() {
-		allData[valTuple.GetKey()] = valTuple.GetValue()
+// Clear clears all data across all shards. While the Map is resized, the shards of both tables are cleared.
+func (m *Map) Clear() {
+	if m.wal != nil {
+		if !m.clearLogged() {
+			return
+		}
+	} else {
+		for t := m.table.Load(); t != nil; t = t.next.Load() {
+			for _, shard := range t.shards {
+				shard.gate.RLock()
+				if !shard.evacuated.Load() {
+					shard.Clear()
+				}
+				shard.gate.RUnlock()
+			}
+		}
 	}
 
-	return allData
+	m.watchers.publish(EventClear, nil)
 }
 
-// Clear clears all data across all shards.
-func (m *Map) Clear() {
-	for _, shard := range m.shards {
+// clearLogged clears all shards of a Map with a write-ahead log and logs the Clear. It reports whether the Map was
+// cleared, which is not the case if the Clear cannot be logged.
+func (m *Map) clearLogged() bool {
+	// Writers are stopped, so the log cannot contain changes that are applied before the Clear
+	t := m.lockTable()
+	defer unlockTable(t)
+
+	record, err := m.wal.record(walOpClear, "", nil)
+	if err != nil {
+		return false
+	}
+
+	for _, shard := range t.shards {
 		shard.Clear()
 	}
+
+	return m.appendLog(record) == nil
 }
 
-// Get returns the value for given key or an error.
+// Get returns the value for given key or a KeyError wrapping ErrNotFound or ErrExpired.
 func (m *Map) Get(key string) (interface{}, error) {
 	keyHash, shard := m.getKeyHashAndShardFromKey(key)
-	val, err := shard.Get(keyHash)
+	val, err := shard.Get(keyHash, key)
+	shard.stats.lookup(err == nil)
 
 	if err != nil {
 		return nil, err
@@ -181,6 +453,20 @@ func (m *Map) Get(key string) (interface{}, error) {
 	return val, nil
 }
 
+// GetWithVersion returns the value and version for key or a KeyError wrapping ErrNotFound or ErrExpired.
+// The version increases with every write to the key, see Tuple.GetVersion.
+func (m *Map) GetWithVersion(key string) (interface{}, uint64, error) {
+	keyHash, shard := m.getKeyHashAndShardFromKey(key)
+	tuple, err := getTuple(shard.Shard, keyHash, key)
+	shard.stats.lookup(err == nil)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return tuple.GetValue(), versionOf(tuple), nil
+}
+
 // MustGet returns the value for a given key or nil.
 func (m *Map) MustGet(key string) interface{} {
 	val, _ := m.Get(key)
@@ -188,42 +474,56 @@ func (m *Map) MustGet(key string) interface{} {
 	return val
 }
 
-func (m *Map) Set(key string, value interface{}) {
-	keyHash, shard := m.getKeyHashAndShardFromKey(key)
-	shard.Set(keyHash, NewTuple(key, value))
+// Set sets the value for key. The entry expires after the default TTL if one is configured.
+// It returns ErrCapacity if the shard of key is full and cannot evict entries.
+func (m *Map) Set(key string, value interface{}) error {
+	return m.SetWithTTL(key, value, m.defaultTTL)
+}
+
+// SetWithTTL sets the value for key that expires after ttl. A ttl <= 0 never expires.
+// It returns ErrCapacity if the shard of key is full and cannot evict entries.
+func (m *Map) SetWithTTL(key string, value interface{}, ttl time.Duration) error {
+	return m.storeTuple(NewTupleWithExpiry(key, value, m.expiryFromTTL(ttl)))
 }
 
 func (m *Map) Has(key string) bool {
 	keyHash, shard := m.getKeyHashAndShardFromKey(key)
-	has := shard.Has(keyHash)
+	has := shard.Has(keyHash, key)
+	shard.stats.lookup(has)
 
 	return has
 }
 
+// Remove removes key. For a Map created by Open, Remove does nothing if the removal cannot be logged. See Open.
 func (m *Map) Remove(key string) {
-	keyHash, shard := m.getKeyHashAndShardFromKey(key)
-	shard.Remove(keyHash)
-}
+	keyHash, shard := m.getKeyHashAndLockShardFromKey(key)
+	defer m.unlockShard(shard)
 
-// UnmarshalJSON supports custom unmarshaling by implementing json.Unmarshaler interface.
-func (m *Map) UnmarshalJSON(b []byte) error {
-	if m.shards == nil && m.shardCount == 0 { // This is an empty Map
-		m.applyDefaults()
-		m.initShards()
+	record, err := m.encodeRemove(key)
+	if err != nil {
+		return
 	}
 
-	flatMap := make(map[string]interface{})
-	err := json.Unmarshal(b, &flatMap)
+	if removeKey(shard.Shard, keyHash, key) {
+		shard.stats.remove()
 
-	if err != nil {
-		return err
+		_ = m.appendLog(record)
 	}
+}
+
+// UnmarshalJSON supports custom unmarshaling by implementing json.Unmarshaler interface.
+// Values are decoded with the ValueDecoder set by WithValueDecoder, see DecodeJSON.
+func (m *Map) UnmarshalJSON(b []byte) error {
+	m.initEmpty()
+
+	// Reject malformed documents before any entry is set
+	if !json.Valid(b) {
+		var v interface{}
 
-	for k, v := range flatMap {
-		m.Set(k, v)
+		return json.Unmarshal(b, &v)
 	}
 
-	return nil
+	return m.DecodeJSON(bytes.NewReader(b))
 }
 
 // MarshalJSON supports custom marshaling by implementing json.Marshaler interface.
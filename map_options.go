@@ -1,5 +1,9 @@
 package shardedmap
 
+import (
+	"time"
+)
+
 // MapOption defines an option that can be set in Map constructor.
 type MapOption func(m *Map)
 
@@ -23,3 +27,154 @@ func WithCustomKeyHashFunc(f KeyHashFunc) MapOption {
 		m.keyHashFunc = f
 	}
 }
+
+// WithHashSeed hashes keys with the given secret seed. Keys that collide for one seed do not collide for others,
+// so attackers that control the keys cannot pile them up in a single shard or bucket without knowing the seed.
+// Keys are hashed with HashXXHash64Seeded unless WithCustomSeededKeyHashFunc is used. A seed replaces the function
+// set by WithCustomKeyHashFunc.
+func WithHashSeed(seed uint64) MapOption {
+	return func(m *Map) {
+		m.hashSeed = seed
+		m.seeded = true
+	}
+}
+
+// WithRandomHashSeed is like WithHashSeed with a seed read from crypto/rand for every Map. See Map.HashSeed.
+func WithRandomHashSeed() MapOption {
+	return func(m *Map) {
+		m.hashSeed = randomSeed()
+		m.seeded = true
+	}
+}
+
+// WithCustomSeededKeyHashFunc specifies the function for hashing keys with the seed of WithHashSeed.
+func WithCustomSeededKeyHashFunc(f SeededKeyHashFunc) MapOption {
+	return func(m *Map) {
+		m.seededHashFunc = f
+	}
+}
+
+// WithShardSelector specifies how keys are distributed to the shards. Use JumpHashSelector or
+// RendezvousSelector to keep most keys assigned to the same shard index when the Map is resized.
+func WithShardSelector(selector ShardSelector) MapOption {
+	return func(m *Map) {
+		m.shardSelector = selector
+	}
+}
+
+// WithPowerOfTwoShards rounds the shard count of New and Map.Resize up to the next power of two. With the default
+// ModuloSelector, the shard of a key is then selected by masking its hash instead of computing a modulo.
+func WithPowerOfTwoShards() MapOption {
+	return func(m *Map) {
+		m.powerOfTwo = true
+	}
+}
+
+// WithDefaultTTL specifies the time to live for entries added by Set. A ttl <= 0 disables expiry.
+func WithDefaultTTL(ttl time.Duration) MapOption {
+	return func(m *Map) {
+		m.defaultTTL = ttl
+	}
+}
+
+// WithJanitor starts a goroutine per shard that removes expired entries at the given interval.
+// Call Map.Close to stop them.
+func WithJanitor(interval time.Duration) MapOption {
+	return func(m *Map) {
+		m.janitorInterval = interval
+	}
+}
+
+// WithMaxEntries limits the number of entries in the map. Each shard receives an equal share of n.
+// The limit is enforced by shards that implement ConfigurableShard. The shards created by NewLRUShard evict the
+// least recently used entry to make room, the shards created by NewMutexShard and NewAtomicShard reject new keys
+// with ErrCapacity once they are full.
+func WithMaxEntries(n int) MapOption {
+	return func(m *Map) {
+		m.maxEntries = uint(n)
+	}
+}
+
+// WithOnEvict registers a callback that is invoked for every entry that leaves the map by Remove, Clear,
+// expiry or capacity eviction. The callback is invoked synchronously unless WithAsyncEviction is used.
+func WithOnEvict(f EvictionFunc) MapOption {
+	return func(m *Map) {
+		m.onEvict = f
+	}
+}
+
+// WithAsyncEviction delivers eviction callbacks from a separate goroutine through a queue holding up to
+// queueSize evictions. Callbacks run one at a time in the order the evictions were queued. Operations causing
+// evictions block while the queue is full. Evictions caused by a callback itself do not wait for the queue, they
+// are delivered by the same goroutine once the callback returns, so callbacks may modify the Map.
+// Call Map.Close to deliver the remaining evictions and stop the goroutine.
+func WithAsyncEviction(queueSize int) MapOption {
+	return func(m *Map) {
+		m.evictionQueueSize = queueSize
+	}
+}
+
+// WithAutoGrow doubles the number of shards in the background once a shard holds more than maxEntriesPerShard
+// entries, until the Map has maxShardCount shards. See Map.Resize.
+func WithAutoGrow(maxEntriesPerShard, maxShardCount int) MapOption {
+	return func(m *Map) {
+		m.growThreshold = uint(maxEntriesPerShard)
+		m.growMaxShardCount = uint(maxShardCount)
+	}
+}
+
+// WithStats maintains the counters reported by Map.Stats. Every operation then updates an atomic counter of
+// its shard and writers measure the time they wait for the shard lock.
+func WithStats() MapOption {
+	return func(m *Map) {
+		m.stats = true
+	}
+}
+
+// WithBatchWorkers processes the shards of SetMany, GetMany, HasMany and RemoveMany with up to n goroutines.
+// By default, the shards are processed one after another by the calling goroutine.
+func WithBatchWorkers(n int) MapOption {
+	return func(m *Map) {
+		m.batchWorkers = n
+	}
+}
+
+// WithOptimisticRetries sets how often Map.UpdateOptimistic runs a transaction again after a conflict before it
+// returns ErrConflict. Defaults to DefaultOptimisticRetries.
+func WithOptimisticRetries(n int) MapOption {
+	return func(m *Map) {
+		m.optimisticRetries = n
+	}
+}
+
+// WithValueDecoder specifies how Map.UnmarshalJSON and Map.DecodeJSON decode values. Use DecodeAs to decode
+// all values into a type. Defaults to DecodeAny.
+func WithValueDecoder(decoder ValueDecoder) MapOption {
+	return func(m *Map) {
+		m.valueDecoder = decoder
+	}
+}
+
+// WithValueCodec specifies the codec for values in snapshots written by Map.WriteSnapshot. Defaults to GobCodec.
+func WithValueCodec(codec ValueCodec) MapOption {
+	return func(m *Map) {
+		m.valueCodec = codec
+	}
+}
+
+// WithWALSync specifies when the write-ahead log of a Map created by Open is synced. The interval is only used
+// by WALSyncInterval and defaults to one second. The policy defaults to WALSyncAlways.
+func WithWALSync(policy WALSyncPolicy, interval time.Duration) MapOption {
+	return func(m *Map) {
+		m.walSyncPolicy = policy
+		m.walSyncInterval = interval
+	}
+}
+
+// WithWALCompaction compacts the write-ahead log of a Map created by Open in the background once it grows
+// beyond maxSize bytes. See Map.Compact.
+func WithWALCompaction(maxSize int64) MapOption {
+	return func(m *Map) {
+		m.walCompactSize = maxSize
+	}
+}
@@ -1,7 +1,9 @@
 package shardedmap_test
 
 import (
+	"fmt"
 	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"testing"
 )
@@ -22,6 +24,7 @@ var mapTestMatrix = []struct { //nolint:gochecknoglobals
 	{8, shardedmap.NewMutexShard, shardedmap.HashFnv1a32},
 	{8, shardedmap.NewAtomicShard, shardedmap.HashFnv1a64},
 	{8, shardedmap.NewAtomicShard, shardedmap.HashFnv1a32},
+	{8, shardedmap.NewLRUShard, shardedmap.HashFnv1a64},
 
 	// Shard Count 32
 	{32, shardedmap.NewMutexShard, shardedmap.HashFnv1a64},
@@ -47,3 +50,59 @@ func TestMapRunSuiteMatrix(t *testing.T) {
 		))
 	}
 }
+
+func TestMapHashCollisions(t *testing.T) {
+	for _, provider := range []shardedmap.ShardProviderFunc{shardedmap.NewMutexShard, shardedmap.NewAtomicShard} {
+		// Every key produces the same hash
+		instance := shardedmap.New(
+			shardedmap.WithShardCount(4),
+			shardedmap.WithCustomShardProvider(provider),
+			shardedmap.WithCustomKeyHashFunc(func(key string) uint { return 7 }),
+		)
+
+		testData := map[string]interface{}{"a": 1, "b": "two", "c": 3.0, "d": true}
+		for k, v := range testData {
+			instance.Set(k, v)
+		}
+
+		assert.Equal(t, len(testData), instance.Count())
+		assert.Equal(t, testData, instance.All())
+
+		for k, v := range testData {
+			assert.Equal(t, v, instance.MustGet(k))
+		}
+
+		instance.Remove("b")
+		assert.False(t, instance.Has("b"))
+		assert.Equal(t, 1, instance.MustGet("a"))
+		assert.Equal(t, 3.0, instance.MustGet("c"))
+		assert.Equal(t, len(testData)-1, instance.Count())
+
+		_, err := instance.Get("e")
+		assert.Error(t, err)
+	}
+}
+
+func TestMapRunSuiteWithPowerOfTwoShards(t *testing.T) {
+	for _, shardCount := range []int{1, 5, 32} {
+		suite.Run(t, NewMapTestSuite(
+			shardedmap.New(shardedmap.WithShardCount(shardCount), shardedmap.WithPowerOfTwoShards()),
+		))
+	}
+}
+
+func TestPowerOfTwoShardCount(t *testing.T) {
+	instance := shardedmap.New(shardedmap.WithShardCount(5), shardedmap.WithPowerOfTwoShards())
+	assert.Equal(t, 8, instance.ShardCount())
+
+	for i := 0; i < 100; i++ {
+		instance.Set(fmt.Sprint(i), i)
+	}
+
+	assert.NoError(t, instance.Resize(9))
+	assert.Equal(t, 16, instance.ShardCount())
+	assert.Equal(t, 100, instance.Count())
+
+	assert.NoError(t, instance.Resize(16))
+	assert.Equal(t, 16, instance.ShardCount())
+}
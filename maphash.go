@@ -0,0 +1,16 @@
+package shardedmap
+
+import (
+	"hash/maphash"
+)
+
+// NewHashMaphash returns a KeyHashFunc based on hash/maphash, the wyhash derived hash of Go maps.
+// Every call creates a new random seed, so the hashes differ between functions and processes. This makes it
+// infeasible to craft keys that collide, which protects maps holding user-controlled keys from hash flooding.
+func NewHashMaphash() KeyHashFunc {
+	seed := maphash.MakeSeed()
+
+	return func(key string) uint {
+		return uint(maphash.String(seed, key))
+	}
+}
@@ -1,82 +1,259 @@
 package shardedmap
 
 import (
-	"errors"
 	"sync"
+	"time"
 )
 
 // NewMutexShard creates a new Shard.
 func NewMutexShard() Shard {
-	return &MutexShard{ //nolint:exhaustivestruct
-		data: make(ShardDataMap),
-	}
+	return &MutexShard{data: make(ShardDataMap)} //nolint:exhaustivestruct
 }
 
 // MutexShard represents a shard used in Map.
 type MutexShard struct {
-	mu   sync.RWMutex
-	data ShardDataMap
+	mu         sync.RWMutex
+	data       ShardDataMap
+	count      uint
+	maxEntries uint
+	onEvict    EvictionHandler
+}
+
+// Configure see: interfaces.ConfigurableShard.
+func (s *MutexShard) Configure(cfg ShardConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maxEntries = cfg.MaxEntries
+	s.onEvict = cfg.OnEvict
 }
 
-// All see: interfaces.Collection.
-func (s *MutexShard) All() ShardDataMap {
+// full reports whether no more keys can be added. The caller must hold the lock.
+func (s *MutexShard) full() bool {
+	return s.maxEntries > 0 && s.count >= s.maxEntries
+}
+
+// Snapshot see: interfaces.Collection. It returns a copy of the data.
+func (s *MutexShard) Snapshot() ShardDataMap {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return s.data
+	return s.data.Clone()
 }
 
 // Get see: interfaces.Collection.
-func (s *MutexShard) Get(key uint) (interface{}, error) {
+func (s *MutexShard) Get(keyHash uint, key string) (interface{}, error) {
+	tuple, err := s.GetTuple(keyHash, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return tuple.GetValue(), nil
+}
+
+// GetTuple see: interfaces.TupleGetter.
+func (s *MutexShard) GetTuple(keyHash uint, key string) (ShardTuple, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	tuple, ok := s.data[key]
-	if !ok {
-		return nil, errors.New("not found") // nolint
+	tuple, ok := s.data.Lookup(keyHash, key)
+	if err := lookupError(key, tuple, ok); err != nil {
+		return nil, err
 	}
 
-	return tuple.GetValue(), nil
+	return tuple, nil
 }
 
 // Set see: interfaces.Collection.
-func (s *MutexShard) Set(key uint, value ShardTuple) {
+func (s *MutexShard) Set(keyHash uint, tuple ShardTuple) error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.data[key] = value
+
+	if s.full() {
+		if _, ok := s.data.Lookup(keyHash, tuple.GetKey()); !ok {
+			s.mu.Unlock()
+
+			return newKeyError(tuple.GetKey(), ErrCapacity)
+		}
+	}
+
+	previous, replaced := s.data.Store(keyHash, tuple)
+	if !replaced {
+		s.count++
+	}
+
+	s.mu.Unlock()
+
+	if replaced && isExpiredNow(previous) {
+		s.onEvict.notify(EvictionReasonExpired, previous)
+	}
+
+	return nil
 }
 
 // Has see: interfaces.Collection.
-func (s *MutexShard) Has(key uint) bool {
+func (s *MutexShard) Has(keyHash uint, key string) bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if _, ok := s.data[key]; ok {
-		return true
-	}
+	tuple, ok := s.data.Lookup(keyHash, key)
 
-	return false
+	return ok && !isExpiredNow(tuple)
 }
 
 // Remove see: interfaces.Collection.
-func (s *MutexShard) Remove(key uint) {
+func (s *MutexShard) Remove(keyHash uint, key string) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	delete(s.data, key)
+
+	removed, ok := s.data.Delete(keyHash, key)
+	if ok {
+		s.count--
+	}
+
+	s.mu.Unlock()
+
+	if ok {
+		s.onEvict.notify(EvictionReasonRemoved, removed)
+	}
 }
 
 // Count see: interfaces.Collection.
 func (s *MutexShard) Count() uint {
-	// TODO: Check if builtin len function requires a read lock here
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return uint(len(s.data))
+	return s.count
+}
+
+// RemoveExpired see: interfaces.Collection.
+func (s *MutexShard) RemoveExpired(now int64) uint {
+	s.mu.Lock()
+
+	expired := s.data.DeleteExpired(now)
+	s.count -= uint(len(expired))
+
+	s.mu.Unlock()
+
+	s.onEvict.notify(EvictionReasonExpired, expired...)
+
+	return uint(len(expired))
 }
 
 // Clear see: interfaces.Collection.
 func (s *MutexShard) Clear() {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+
+	cleared := s.data
 	s.data = make(ShardDataMap)
+	s.count = 0
+
+	s.mu.Unlock()
+
+	if s.onEvict != nil {
+		cleared.Range(func(_ uint, tuple ShardTuple) bool {
+			s.onEvict.notify(EvictionReasonCleared, tuple)
+
+			return true
+		})
+	}
+}
+
+// Compute see: interfaces.Collection.
+func (s *MutexShard) Compute(keyHash uint, key string, fn ComputeFunc) (ShardTuple, error) {
+	res := func() computeResult {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		res := s.data.compute(keyHash, key, fn, func() ShardDataMap { return s.data }, s.full())
+		s.count = uint(int(s.count) + res.delta)
+
+		return res
+	}()
+
+	if res.dropped != nil {
+		s.onEvict.notify(res.reason, res.dropped)
+	}
+
+	return res.tuple, res.err
+}
+
+// GetMany see: interfaces.BatchShard.
+func (s *MutexShard) GetMany(keyHashes []uint, keys []string, fn func(i int, value interface{})) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now().UnixNano()
+
+	for i, key := range keys {
+		if tuple, ok := s.data.Lookup(keyHashes[i], key); ok && !IsExpired(tuple, now) {
+			fn(i, tuple.GetValue())
+		}
+	}
+}
+
+// SetMany see: interfaces.BatchShard.
+func (s *MutexShard) SetMany(keyHashes []uint, tuples []ShardTuple) []error {
+	var (
+		errs    []error
+		expired []ShardTuple
+	)
+
+	now := time.Now().UnixNano()
+
+	s.mu.Lock()
+
+	for i, tuple := range tuples {
+		if s.full() {
+			if _, ok := s.data.Lookup(keyHashes[i], tuple.GetKey()); !ok {
+				if errs == nil {
+					errs = make([]error, len(tuples))
+				}
+
+				errs[i] = newKeyError(tuple.GetKey(), ErrCapacity)
+
+				continue
+			}
+		}
+
+		previous, replaced := s.data.Store(keyHashes[i], tuple)
+
+		switch {
+		case !replaced:
+			s.count++
+		case IsExpired(previous, now):
+			expired = append(expired, previous)
+		}
+	}
+
+	s.mu.Unlock()
+
+	s.onEvict.notify(EvictionReasonExpired, expired...)
+
+	return errs
+}
+
+// RemoveMany see: interfaces.BatchShard.
+func (s *MutexShard) RemoveMany(keyHashes []uint, keys []string, fn func(i int)) {
+	var removed, expired []ShardTuple
+
+	s.mu.Lock()
+
+	now := time.Now().UnixNano()
+
+	for i, key := range keys {
+		if tuple, ok := s.data.Delete(keyHashes[i], key); ok {
+			s.count--
+
+			if IsExpired(tuple, now) {
+				expired = append(expired, tuple)
+			} else {
+				removed = append(removed, tuple)
+				fn(i)
+			}
+		}
+	}
+
+	s.mu.Unlock()
+
+	s.onEvict.notify(EvictionReasonRemoved, removed...)
+	s.onEvict.notify(EvictionReasonExpired, expired...)
 }
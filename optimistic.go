@@ -0,0 +1,177 @@
+package shardedmap
+
+import (
+	"sort"
+)
+
+// OptimisticTx is a transaction on a Map that does not lock any shard until it is committed,
+// see Map.UpdateOptimistic. It must only be used by the function passed to UpdateOptimistic.
+type OptimisticTx struct {
+	m *Map
+	// reads holds the version of every key read by the transaction, 0 if the key was absent.
+	reads map[string]uint64
+	// writes holds the buffered changes by key in the order of keys. A nil tuple removes the key.
+	writes map[string]ShardTuple
+	keys   []string
+	done   bool
+}
+
+// UpdateOptimistic runs fn in an optimistic transaction. Unlike Update, fn runs without locking shards and the
+// transaction only records the versions of the keys it reads. Once fn returns nil, the shards of all read and
+// written keys are locked in index order and the versions are compared to the current ones. If none of the read
+// keys was modified in the meantime, the changes are applied like by Update. Otherwise, fn runs again up to the
+// number of times set by WithOptimisticRetries before UpdateOptimistic returns ErrConflict.
+//
+// If fn returns an error, the changes are discarded and UpdateOptimistic returns the error. As fn may run more
+// than once, it must not have side effects beyond tx.
+func (m *Map) UpdateOptimistic(fn func(tx *OptimisticTx) error) error {
+	for retries := 0; ; retries++ {
+		tx := &OptimisticTx{ //nolint:exhaustivestruct
+			m:      m,
+			reads:  make(map[string]uint64),
+			writes: make(map[string]ShardTuple),
+		}
+
+		err := fn(tx)
+		tx.done = true
+
+		if err != nil {
+			return err
+		}
+
+		if err := tx.commit(); err != ErrConflict || retries >= m.optimisticRetries { //nolint:errorlint
+			return err
+		}
+	}
+}
+
+// Get returns the value for key including the changes made by tx or a KeyError wrapping ErrNotFound or
+// ErrExpired. The version of the first read of a key is validated on commit.
+func (tx *OptimisticTx) Get(key string) (interface{}, error) {
+	if tx.done {
+		return nil, ErrTxDone
+	}
+
+	if tuple, ok := tx.writes[key]; ok {
+		if tuple == nil {
+			return nil, newKeyError(key, ErrNotFound)
+		}
+
+		return tuple.GetValue(), nil
+	}
+
+	value, version, err := tx.m.GetWithVersion(key)
+
+	if _, ok := tx.reads[key]; !ok {
+		tx.reads[key] = version
+	}
+
+	return value, err
+}
+
+// Has checks the existence of key including the changes made by tx.
+func (tx *OptimisticTx) Has(key string) bool {
+	_, err := tx.Get(key)
+
+	return err == nil
+}
+
+// Set sets the value for key when the transaction is committed. The entry expires after the default TTL if one
+// is configured.
+func (tx *OptimisticTx) Set(key string, value interface{}) error {
+	return tx.write(key, tx.m.newTuple(key, value))
+}
+
+// Remove removes key when the transaction is committed.
+func (tx *OptimisticTx) Remove(key string) error {
+	return tx.write(key, nil)
+}
+
+func (tx *OptimisticTx) write(key string, tuple ShardTuple) error {
+	if tx.done {
+		return ErrTxDone
+	}
+
+	if _, ok := tx.writes[key]; !ok {
+		tx.keys = append(tx.keys, key)
+	}
+
+	tx.writes[key] = tuple
+
+	return nil
+}
+
+// commit locks the shards of all keys, validates the read versions and applies the changes.
+func (tx *OptimisticTx) commit() error {
+	for {
+		t := tx.m.stableTable()
+		locked := &Tx{m: tx.m, table: t, writes: make(map[string]txWrite)} //nolint:exhaustivestruct
+
+		// A Resize started after the table was loaded
+		if locked.lockAll(tx.indexes(t)) != nil {
+			locked.release()
+
+			continue
+		}
+
+		if !tx.valid(t) {
+			locked.release()
+
+			return ErrConflict
+		}
+
+		for _, key := range tx.keys {
+			// The shards are locked already, so write cannot fail
+			_ = locked.write(key, tx.writes[key])
+		}
+
+		err := locked.commit()
+		locked.release()
+
+		return err
+	}
+}
+
+// indexes returns the indexes of the shards in t of all read and written keys in ascending order.
+func (tx *OptimisticTx) indexes(t *shardTable) []uint {
+	seen := make(map[uint]bool)
+	indexes := make([]uint, 0, len(tx.reads)+len(tx.keys))
+
+	add := func(key string) {
+		if index := t.index(tx.m.getKeyHash(key)); !seen[index] {
+			seen[index] = true
+			indexes = append(indexes, index)
+		}
+	}
+
+	for key := range tx.reads {
+		add(key)
+	}
+
+	for _, key := range tx.keys {
+		add(key)
+	}
+
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	return indexes
+}
+
+// valid reports whether the versions of all read keys are unchanged. The shards must be locked.
+func (tx *OptimisticTx) valid(t *shardTable) bool {
+	for key, version := range tx.reads {
+		keyHash := tx.m.getKeyHash(key)
+
+		var current uint64
+
+		if tuple, err := getTuple(t.shards[t.index(keyHash)].Shard, keyHash, key); err == nil {
+			current = versionOf(tuple)
+		}
+
+		if current != version {
+			return false
+		}
+	}
+
+	return true
+}
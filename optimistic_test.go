@@ -0,0 +1,185 @@
+package shardedmap_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetWithVersion(t *testing.T) {
+	for _, provider := range resizeTestProviders {
+		instance := shardedmap.New(shardedmap.WithShardCount(1), shardedmap.WithCustomShardProvider(provider))
+
+		_, version, err := instance.GetWithVersion("a")
+		assert.ErrorIs(t, err, shardedmap.ErrNotFound)
+		assert.Zero(t, version)
+
+		assert.NoError(t, instance.Set("a", 1))
+		value, first, err := instance.GetWithVersion("a")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, value)
+
+		// Versions are counted per shard, not across all Maps
+		assert.Equal(t, uint64(1), first)
+
+		assert.NoError(t, instance.Set("b", 1))
+		assert.NoError(t, instance.Set("a", 2))
+
+		_, second, err := instance.GetWithVersion("a")
+		assert.NoError(t, err)
+		assert.Greater(t, second, first)
+
+		// Moving entries to other shards keeps their version
+		assert.NoError(t, instance.Resize(3))
+		_, moved, _ := instance.GetWithVersion("a")
+		assert.Equal(t, second, moved)
+
+		// A key that is removed and set again gets a higher version, also after it was moved to another shard
+		instance.Remove("a")
+		assert.NoError(t, instance.Resize(5))
+		assert.NoError(t, instance.Set("a", 3))
+
+		_, third, _ := instance.GetWithVersion("a")
+		assert.Greater(t, third, second)
+	}
+}
+
+func TestVersionsOfBatchesAndTransactions(t *testing.T) {
+	instance := shardedmap.New()
+
+	assert.NoError(t, instance.SetMany(map[string]interface{}{"a": 1, "b": 2}))
+	_, first, _ := instance.GetWithVersion("a")
+	assert.NotZero(t, first)
+
+	assert.NoError(t, instance.Update(func(tx *shardedmap.Tx) error {
+		return tx.Set("a", 2)
+	}))
+	_, second, _ := instance.GetWithVersion("a")
+	assert.Greater(t, second, first)
+
+	_, _ = instance.Upsert("a", func(old interface{}, exists bool) interface{} { return 3 })
+	_, third, _ := instance.GetWithVersion("a")
+	assert.Greater(t, third, second)
+}
+
+func TestUpdateOptimistic(t *testing.T) {
+	const workers, increments = 4, 100
+
+	instance := shardedmap.New(shardedmap.WithOptimisticRetries(workers * increments))
+	assert.NoError(t, instance.Set("counter", 0))
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < increments; i++ {
+				assert.NoError(t, instance.UpdateOptimistic(func(tx *shardedmap.OptimisticTx) error {
+					counter, err := tx.Get("counter")
+					if err != nil {
+						return err
+					}
+
+					if err := tx.Set(fmt.Sprintf("%d-%d", w, i), true); err != nil {
+						return err
+					}
+
+					return tx.Set("counter", counter.(int)+1) //nolint:forcetypeassert
+				}))
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, workers*increments, instance.MustGet("counter"))
+	assert.Equal(t, workers*increments+1, instance.Count())
+}
+
+func TestUpdateOptimisticConflict(t *testing.T) {
+	instance := shardedmap.New(shardedmap.WithOptimisticRetries(2))
+	assert.NoError(t, instance.Set("a", 0))
+
+	runs := 0
+
+	err := instance.UpdateOptimistic(func(tx *shardedmap.OptimisticTx) error {
+		runs++
+
+		if tx.Has("a") && tx.Has("absent") {
+			return nil
+		}
+
+		// Another writer modifies the key after it was read
+		assert.NoError(t, instance.Set("a", runs))
+
+		return tx.Set("b", runs)
+	})
+
+	assert.ErrorIs(t, err, shardedmap.ErrConflict)
+	assert.Equal(t, 3, runs)
+	assert.False(t, instance.Has("b"))
+
+	// A key that is added after it was read as absent is a conflict too
+	runs = 0
+	err = instance.UpdateOptimistic(func(tx *shardedmap.OptimisticTx) error {
+		runs++
+
+		if !tx.Has("c") && runs == 1 {
+			assert.NoError(t, instance.Set("c", true))
+		}
+
+		return tx.Remove("a")
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, runs)
+	assert.False(t, instance.Has("a"))
+}
+
+func TestUpdateOptimisticEvictionCallbackMayWrite(t *testing.T) {
+	var instance *shardedmap.Map
+
+	instance = shardedmap.New(
+		shardedmap.WithShardCount(1),
+		shardedmap.WithOnEvict(func(key string, value interface{}, reason shardedmap.EvictionReason) {
+			assert.NoError(t, instance.Set(key+"-removed", value))
+		}),
+	)
+	assert.NoError(t, instance.Set("a", 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := instance.Watch(ctx, shardedmap.WatchPrefix("b"))
+	done := make(chan error)
+
+	go func() {
+		done <- instance.UpdateOptimistic(func(tx *shardedmap.OptimisticTx) error {
+			if err := tx.Set("b", 2); err != nil {
+				return err
+			}
+
+			return tx.Remove("a")
+		})
+	}()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("eviction callback is blocked")
+	}
+
+	assert.Equal(t, map[string]interface{}{"a-removed": 1, "b": 2}, instance.All())
+
+	e := <-events
+	assert.Equal(t, shardedmap.EventSet, e.Type)
+	assert.Equal(t, "b", e.Key)
+}
@@ -0,0 +1,20 @@
+package shardedmap
+
+// cacheLineSize is the cache line size of common CPUs. Counters that are updated concurrently by different
+// cores are padded to a multiple of it, so they never share a cache line (false sharing).
+const cacheLineSize = 64
+
+// isPowerOfTwo reports whether n is a power of two.
+func isPowerOfTwo(n uint) bool {
+	return n != 0 && n&(n-1) == 0
+}
+
+// nextPowerOfTwo returns the smallest power of two that is greater than or equal to n.
+func nextPowerOfTwo(n uint) uint {
+	p := uint(1)
+	for p < n {
+		p <<= 1
+	}
+
+	return p
+}
@@ -0,0 +1,411 @@
+package shardedmap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// The snapshot format written by Map.WriteSnapshot. All integers are little endian, varints use encoding/binary.
+//
+//	header: magic [8]byte | version byte | uvarint len(codec) | codec | uint32 checksum
+//	block:  uvarint n > 0 | n * entry | uint32 checksum
+//	entry:  uvarint len(key) | key | varint expiry | uvarint len(value) | value
+//	end:    uvarint 0 | uint32 checksum
+//
+// Every checksum is the CRC-32C of all bytes of the stream before it, so it covers the preceding blocks too.
+// Each shard is written as one block. Shards without entries are skipped.
+const (
+	snapshotMagic   = "SHRDMAP\x00"
+	snapshotVersion = 1
+
+	// maxSnapshotFieldSize limits the allocation for a single key or value of a corrupted snapshot.
+	maxSnapshotFieldSize = 1 << 30
+)
+
+// WriteSnapshot writes all entries of the map to w in a versioned binary format. Values are encoded with the
+// ValueCodec set by WithValueCodec. The shards are written one after another, each captured at a single point in
+// time like SnapshotPerShard, so writers are never stopped. Expired entries are skipped.
+func (m *Map) WriteSnapshot(w io.Writer) error {
+	sw := newSnapshotWriter(w)
+	sw.writeHeader(snapshotMagic, m.valueCodec.Name())
+
+	now := time.Now().UnixNano()
+
+	for _, shard := range m.stableTable().shards {
+		var tuples []ShardTuple
+
+		shard.Snapshot().Range(func(_ uint, tuple ShardTuple) bool {
+			if !IsExpired(tuple, now) {
+				tuples = append(tuples, tuple)
+			}
+
+			return true
+		})
+
+		if len(tuples) == 0 {
+			continue
+		}
+
+		if err := sw.writeBlock(tuples, m.valueCodec); err != nil {
+			return err
+		}
+	}
+
+	sw.writeUvarint(0)
+	sw.writeChecksum()
+
+	return sw.flush()
+}
+
+// ReadSnapshot adds the entries of a snapshot written by WriteSnapshot to the map. Existing entries with the same
+// keys are replaced, entries that expired in the meantime are skipped. The snapshot must have been written with a
+// ValueCodec of the same name.
+//
+// Entries are added block by block after the checksum of the block was verified. If the snapshot is corrupted,
+// ReadSnapshot returns an error wrapping ErrInvalidSnapshot and the entries of the blocks before stay in the map.
+func (m *Map) ReadSnapshot(r io.Reader) error {
+	sr := newSnapshotReader(r)
+
+	if err := sr.readHeader(snapshotMagic, m.valueCodec.Name()); err != nil {
+		return err
+	}
+
+	for {
+		tuples, err := sr.readBlock(m.valueCodec)
+		if err != nil {
+			return err
+		}
+
+		if tuples == nil {
+			return nil
+		}
+
+		now := time.Now().UnixNano()
+
+		for _, tuple := range tuples {
+			if IsExpired(tuple, now) {
+				continue
+			}
+
+			if err := m.storeTuple(tuple); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// storeTuple sets tuple like Set, but keeps the expiry of the tuple. The tuple is stored with Compute to assign
+// its version under the lock of the shard.
+func (m *Map) storeTuple(tuple ShardTuple) error {
+	var stored ShardTuple
+
+	keyHash, shard := m.getKeyHashAndLockShardFromKey(tuple.GetKey())
+	defer m.releaseAndNotify(shard, &stored)
+
+	record, err := m.encodeSet(tuple)
+	if err != nil {
+		return err
+	}
+
+	stamped, err := shard.Compute(keyHash, tuple.GetKey(), func(ShardTuple) (ShardTuple, bool) {
+		return shard.stamp(tuple), true
+	})
+	if err != nil {
+		return err
+	}
+
+	shard.stats.set()
+
+	if err := m.appendLog(record); err != nil {
+		return err
+	}
+
+	stored = stamped
+
+	return nil
+}
+
+// snapshotWriter writes the snapshot format and keeps the checksum of all written bytes.
+// The first error is kept and returned by flush.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	crc uint32
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func newSnapshotWriter(w io.Writer) *snapshotWriter {
+	return &snapshotWriter{w: bufio.NewWriter(w)} //nolint:exhaustivestruct
+}
+
+func (sw *snapshotWriter) write(p []byte) {
+	if sw.err != nil {
+		return
+	}
+
+	sw.crc = crc32.Update(sw.crc, castagnoliTable, p)
+	_, sw.err = sw.w.Write(p)
+}
+
+func (sw *snapshotWriter) writeUvarint(x uint64) {
+	sw.write(sw.buf[:binary.PutUvarint(sw.buf[:], x)])
+}
+
+func (sw *snapshotWriter) writeVarint(x int64) {
+	sw.write(sw.buf[:binary.PutVarint(sw.buf[:], x)])
+}
+
+func (sw *snapshotWriter) writeBytes(p []byte) {
+	sw.writeUvarint(uint64(len(p)))
+	sw.write(p)
+}
+
+func (sw *snapshotWriter) writeChecksum() {
+	var b [4]byte
+
+	binary.LittleEndian.PutUint32(b[:], sw.crc)
+	sw.write(b[:])
+}
+
+// writeHeader writes the header of a snapshot or, with another magic, of a write-ahead log.
+func (sw *snapshotWriter) writeHeader(magic, codec string) {
+	sw.write([]byte(magic))
+	sw.write([]byte{snapshotVersion})
+	sw.writeBytes([]byte(codec))
+	sw.writeChecksum()
+}
+
+// writeEntry writes a single entry. Values are encoded with codec.
+func (sw *snapshotWriter) writeEntry(tuple ShardTuple, codec ValueCodec) error {
+	value, err := codec.Encode(tuple.GetValue())
+	if err != nil {
+		return newKeyError(tuple.GetKey(), err)
+	}
+
+	sw.writeBytes([]byte(tuple.GetKey()))
+	sw.writeVarint(tuple.GetExpiry())
+	sw.writeBytes(value)
+
+	return nil
+}
+
+func (sw *snapshotWriter) writeBlock(tuples []ShardTuple, codec ValueCodec) error {
+	sw.writeUvarint(uint64(len(tuples)))
+
+	for _, tuple := range tuples {
+		if err := sw.writeEntry(tuple, codec); err != nil {
+			return err
+		}
+	}
+
+	sw.writeChecksum()
+
+	return sw.err
+}
+
+func (sw *snapshotWriter) flush() error {
+	if sw.err != nil {
+		return sw.err
+	}
+
+	return sw.w.Flush()
+}
+
+// snapshotReader reads the snapshot format and keeps the checksum of all read bytes.
+type snapshotReader struct {
+	r   *bufio.Reader
+	crc uint32
+	b   [1]byte
+}
+
+// newSnapshotReader returns a reader for r. If r is a bufio.Reader, it is used directly, so the caller can
+// continue reading after the snapshot data.
+func newSnapshotReader(r io.Reader) *snapshotReader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	return &snapshotReader{r: br} //nolint:exhaustivestruct
+}
+
+// invalidSnapshot wraps err in ErrInvalidSnapshot, using io.ErrUnexpectedEOF for an incomplete snapshot.
+func invalidSnapshot(err error) error {
+	if err == io.EOF { //nolint:errorlint
+		err = io.ErrUnexpectedEOF
+	}
+
+	return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err) //nolint:errorlint
+}
+
+// ReadByte implements io.ByteReader for binary.ReadUvarint.
+func (sr *snapshotReader) ReadByte() (byte, error) {
+	b, err := sr.r.ReadByte()
+	if err == nil {
+		sr.b[0] = b
+		sr.crc = crc32.Update(sr.crc, castagnoliTable, sr.b[:])
+	}
+
+	return b, err
+}
+
+func (sr *snapshotReader) read(p []byte) error {
+	if _, err := io.ReadFull(sr.r, p); err != nil {
+		return invalidSnapshot(err)
+	}
+
+	sr.crc = crc32.Update(sr.crc, castagnoliTable, p)
+
+	return nil
+}
+
+func (sr *snapshotReader) readUvarint() (uint64, error) {
+	x, err := binary.ReadUvarint(sr)
+	if err != nil {
+		return 0, invalidSnapshot(err)
+	}
+
+	return x, nil
+}
+
+func (sr *snapshotReader) readVarint() (int64, error) {
+	x, err := binary.ReadVarint(sr)
+	if err != nil {
+		return 0, invalidSnapshot(err)
+	}
+
+	return x, nil
+}
+
+func (sr *snapshotReader) readBytes() ([]byte, error) {
+	n, err := sr.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	if n > maxSnapshotFieldSize {
+		return nil, invalidSnapshot(errFieldTooLarge)
+	}
+
+	p := make([]byte, n)
+
+	return p, sr.read(p)
+}
+
+// verifyChecksum reads a checksum and compares it with the checksum of the bytes read before.
+func (sr *snapshotReader) verifyChecksum() error {
+	expected := sr.crc
+
+	var b [4]byte
+	if err := sr.read(b[:]); err != nil {
+		return err
+	}
+
+	if binary.LittleEndian.Uint32(b[:]) != expected {
+		return invalidSnapshot(errChecksumMismatch)
+	}
+
+	return nil
+}
+
+// readHeader reads the header written by writeHeader.
+func (sr *snapshotReader) readHeader(magic, codec string) error {
+	head := make([]byte, len(magic)+1)
+	if err := sr.read(head); err != nil {
+		return err
+	}
+
+	if string(head[:len(magic)]) != magic {
+		return invalidSnapshot(errBadMagic)
+	}
+
+	if version := head[len(magic)]; version != snapshotVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, version)
+	}
+
+	name, err := sr.readBytes()
+	if err != nil {
+		return err
+	}
+
+	if err := sr.verifyChecksum(); err != nil {
+		return err
+	}
+
+	if string(name) != codec {
+		return fmt.Errorf("%w: written with codec %q, expected %q", ErrInvalidSnapshot, name, codec)
+	}
+
+	return nil
+}
+
+// snapshotEntry is an entry read from a snapshot. The value is decoded once the checksum of its block is verified.
+type snapshotEntry struct {
+	key    string
+	expiry int64
+	value  []byte
+}
+
+func (sr *snapshotReader) readEntry() (snapshotEntry, error) {
+	key, err := sr.readBytes()
+	if err != nil {
+		return snapshotEntry{}, err //nolint:exhaustivestruct
+	}
+
+	expiry, err := sr.readVarint()
+	if err != nil {
+		return snapshotEntry{}, err //nolint:exhaustivestruct
+	}
+
+	value, err := sr.readBytes()
+	if err != nil {
+		return snapshotEntry{}, err //nolint:exhaustivestruct
+	}
+
+	return snapshotEntry{key: string(key), expiry: expiry, value: value}, nil
+}
+
+// readBlock reads the next block and returns its tuples with values decoded by codec. It returns nil at the end
+// of the snapshot.
+func (sr *snapshotReader) readBlock(codec ValueCodec) ([]ShardTuple, error) {
+	n, err := sr.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 0 {
+		return nil, sr.verifyChecksum()
+	}
+
+	var entries []snapshotEntry
+
+	for i := uint64(0); i < n; i++ {
+		entry, err := sr.readEntry()
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := sr.verifyChecksum(); err != nil {
+		return nil, err
+	}
+
+	tuples := make([]ShardTuple, len(entries))
+
+	for i, entry := range entries {
+		value, err := codec.Decode(entry.value)
+		if err != nil {
+			return nil, newKeyError(entry.key, err)
+		}
+
+		tuples[i] = NewTupleWithExpiry(entry.key, value, entry.expiry)
+	}
+
+	return tuples, nil
+}
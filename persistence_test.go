@@ -0,0 +1,121 @@
+package shardedmap_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type persistedValue struct {
+	Name  string
+	Count int
+}
+
+//nolint:gochecknoinits
+func init() {
+	gob.Register(persistedValue{})
+}
+
+func TestWriteAndReadSnapshot(t *testing.T) {
+	source := shardedmap.New(shardedmap.WithShardCount(4))
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, source.Set(fmt.Sprint(i), persistedValue{Name: fmt.Sprint("name-", i), Count: i}))
+	}
+
+	assert.NoError(t, source.Set("int", 42))
+	assert.NoError(t, source.SetWithTTL("ttl", "value", time.Hour))
+	assert.NoError(t, source.SetWithTTL("expired", "value", time.Nanosecond))
+
+	var buf bytes.Buffer
+	assert.NoError(t, source.WriteSnapshot(&buf))
+
+	// The shard count of the target does not matter
+	target := shardedmap.New(shardedmap.WithShardCount(3))
+	assert.NoError(t, target.ReadSnapshot(&buf))
+
+	assert.Equal(t, 102, target.Count())
+	assert.Equal(t, persistedValue{Name: "name-7", Count: 7}, target.MustGet("7"))
+	assert.Equal(t, 42, target.MustGet("int"))
+	assert.True(t, target.Has("ttl"))
+	assert.False(t, target.Has("expired"))
+}
+
+func TestReadSnapshotWithJSONCodec(t *testing.T) {
+	source := shardedmap.New(shardedmap.WithValueCodec(shardedmap.JSONCodec{}))
+	assert.NoError(t, source.Set("a", "text"))
+	assert.NoError(t, source.Set("b", 1))
+
+	var buf bytes.Buffer
+	assert.NoError(t, source.WriteSnapshot(&buf))
+
+	// Snapshots cannot be read with another codec
+	err := shardedmap.New().ReadSnapshot(bytes.NewReader(buf.Bytes()))
+	assert.ErrorIs(t, err, shardedmap.ErrInvalidSnapshot)
+
+	target := shardedmap.New(shardedmap.WithValueCodec(shardedmap.JSONCodec{}))
+	assert.NoError(t, target.ReadSnapshot(&buf))
+	assert.Equal(t, map[string]interface{}{"a": "text", "b": 1.0}, target.All())
+}
+
+func TestReadSnapshotDetectsCorruption(t *testing.T) {
+	source := shardedmap.New(shardedmap.WithShardCount(1))
+	assert.NoError(t, source.Set("key", "value"))
+
+	var buf bytes.Buffer
+	assert.NoError(t, source.WriteSnapshot(&buf))
+
+	data := buf.Bytes()
+
+	// Every truncation and every flipped byte is detected
+	for i := 0; i < len(data); i++ {
+		err := shardedmap.New().ReadSnapshot(bytes.NewReader(data[:i]))
+		assert.ErrorIs(t, err, shardedmap.ErrInvalidSnapshot, "truncated at %d", i)
+
+		corrupted := append([]byte(nil), data...)
+		corrupted[i] ^= 0xff
+
+		target := shardedmap.New()
+		err = target.ReadSnapshot(bytes.NewReader(corrupted))
+		assert.ErrorIs(t, err, shardedmap.ErrInvalidSnapshot, "flipped byte %d", i)
+
+		// The block is only added if its checksum is valid. The last 5 bytes are the end marker.
+		if i < len(data)-5 {
+			assert.Equal(t, 0, target.Count(), "flipped byte %d", i)
+		}
+	}
+}
+
+func TestWriteSnapshotWithConcurrentWriters(t *testing.T) {
+	source := shardedmap.New()
+
+	for i := 0; i < 1000; i++ {
+		assert.NoError(t, source.Set(fmt.Sprint(i), i))
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 1000; i++ {
+			assert.NoError(t, source.Set(fmt.Sprint(i), -i))
+		}
+	}()
+
+	var buf bytes.Buffer
+	assert.NoError(t, source.WriteSnapshot(&buf))
+
+	wg.Wait()
+
+	target := shardedmap.New()
+	assert.NoError(t, target.ReadSnapshot(&buf))
+	assert.Equal(t, 1000, target.Count())
+}
@@ -0,0 +1,332 @@
+package shardedmap
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// shardTable is the array of shards a Map distributes its keys to.
+//
+// While a Map is resized, next points to the table the entries are moved to. Shards are moved one after
+// another. Once a shard is evacuated, it is never modified again and all operations on its keys go to next.
+// When all shards are evacuated, next becomes the current table of the Map.
+type shardTable struct {
+	shards   []*shardSlot
+	count    uint
+	selector ShardSelector
+	next     atomic.Pointer[shardTable]
+
+	// masked is set if count is a power of two and keys are distributed by modulo. The index is then
+	// computed by keyHash & mask, which is cheaper than the modulo of ModuloSelector.
+	masked bool
+	mask   uint
+}
+
+func (t *shardTable) index(keyHash uint) uint {
+	if t.masked {
+		return keyHash & t.mask
+	}
+
+	return t.selector.SelectShard(keyHash, t.count)
+}
+
+// hasEvacuated reports whether any shard of t was evacuated.
+func (t *shardTable) hasEvacuated() bool {
+	for _, shard := range t.shards {
+		if shard.evacuated.Load() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// exceeds reports whether any shard of t holds more than n entries.
+func (t *shardTable) exceeds(n uint) bool {
+	for _, shard := range t.shards {
+		if shard.Count() > n {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newTable creates a table with count configured shards. See WithPowerOfTwoShards for how count is rounded.
+func (m *Map) newTable(count uint) *shardTable {
+	if m.powerOfTwo {
+		count = nextPowerOfTwo(count)
+	}
+
+	t := &shardTable{ //nolint:exhaustivestruct
+		shards:   make([]*shardSlot, count),
+		count:    count,
+		selector: m.shardSelector,
+	}
+
+	if _, ok := m.shardSelector.(ModuloSelector); ok && m.powerOfTwo && isPowerOfTwo(count) {
+		t.masked, t.mask = true, count-1
+	}
+
+	for j := uint(0); j < count; j++ {
+		t.shards[j] = &shardSlot{Shard: m.shardProviderFunc()} //nolint:exhaustivestruct
+
+		if m.stats {
+			t.shards[j].stats = &shardStats{} //nolint:exhaustivestruct
+		}
+
+		if s, ok := t.shards[j].Shard.(ConfigurableShard); ok {
+			cfg := m.shardConfig(j, count)
+			cfg.OnEvict = t.shards[j].deferrable(cfg.OnEvict)
+			s.Configure(cfg)
+		}
+	}
+
+	return t
+}
+
+// readShard returns the shard that holds keyHash for reading. The shard is not locked.
+//
+// A reader may still access a shard that was evacuated after it has been selected. Evacuated shards keep
+// their data, so the reader observes the state right before the evacuation.
+func (m *Map) readShard(keyHash uint) *shardSlot {
+	t := m.table.Load()
+
+	for {
+		shard := t.shards[t.index(keyHash)]
+		if !shard.evacuated.Load() {
+			return shard
+		}
+
+		t = t.next.Load()
+	}
+}
+
+// lockShard returns the shard that holds keyHash with its gate held for modifying the shard, see lockGate.
+// If the Map is being resized, the shard is evacuated first, so every write helps to finish the resize.
+// The gate must be released with releaseShard or unlockShard.
+func (m *Map) lockShard(keyHash uint) *shardSlot {
+	t := m.table.Load()
+
+	for {
+		index := t.index(keyHash)
+		shard := t.shards[index]
+
+		if t.next.Load() != nil {
+			m.evacuate(t, index)
+		}
+
+		m.lockGate(shard)
+
+		if !shard.evacuated.Load() {
+			return shard
+		}
+
+		m.unlockShard(shard)
+
+		t = t.next.Load()
+	}
+}
+
+// lockGate locks the gate of shard for modifying it. Writers share the gate unless the Map has a write-ahead log.
+// Then writers hold it exclusively, so the changes of a shard are logged in the order they are applied.
+// The time spent waiting is added to the statistics of the shard, see WithStats.
+func (m *Map) lockGate(shard *shardSlot) {
+	shard.lockGate(m.wal != nil)
+}
+
+// lockGate locks the gate exclusively or shared and adds the time spent waiting to the statistics of the shard.
+func (s *shardSlot) lockGate(exclusive bool) {
+	if s.stats != nil {
+		start := time.Now()
+		defer func() { s.stats.lockWait.Add(int64(time.Since(start))) }()
+	}
+
+	if exclusive {
+		s.gate.Lock()
+	} else {
+		s.gate.RLock()
+	}
+}
+
+// unlockGate releases the gate locked by lockGate.
+func (s *shardSlot) unlockGate(exclusive bool) {
+	if exclusive {
+		s.gate.Unlock()
+	} else {
+		s.gate.RUnlock()
+	}
+}
+
+// unlockShard releases the gate of a shard returned by lockShard.
+func (m *Map) unlockShard(shard *shardSlot) {
+	shard.unlockGate(m.wal != nil)
+}
+
+// releaseShard releases the gate of a shard returned by lockShard and grows the Map if the shard holds more
+// entries than allowed by WithAutoGrow.
+func (m *Map) releaseShard(shard *shardSlot) {
+	m.unlockShard(shard)
+	m.growIfNeeded(shard)
+}
+
+// growIfNeeded grows the Map if shard holds more entries than allowed by WithAutoGrow.
+func (m *Map) growIfNeeded(shard *shardSlot) {
+	if m.growThreshold > 0 && shard.Count() > m.growThreshold {
+		m.grow()
+	}
+}
+
+// evacuate moves the entries of the shard at index in t to the next table of t.
+func (m *Map) evacuate(t *shardTable, index uint) {
+	shard := t.shards[index]
+
+	shard.gate.Lock()
+	defer shard.gate.Unlock()
+
+	if shard.evacuated.Load() {
+		return
+	}
+
+	next := t.next.Load()
+
+	// The keys of the shard get higher versions in the new shards than all versions they had before, including
+	// the versions of removed keys
+	versions := shard.versions.Load()
+	for _, target := range next.shards {
+		target.observeVersions(versions)
+	}
+
+	shard.Snapshot().Range(func(keyHash uint, tuple ShardTuple) bool {
+		target := next.shards[next.index(keyHash)]
+
+		// Moved tuples keep their version
+		target.gate.RLock()
+		err := target.Set(keyHash, tuple)
+		target.gate.RUnlock()
+
+		// The capacity of the new shards differs from the old ones. Entries that do not fit are evicted.
+		if err != nil {
+			m.evictionHandler().notify(EvictionReasonCapacity, tuple)
+		}
+
+		return true
+	})
+
+	shard.evacuated.Store(true)
+
+	// Waiters registered with the shard must wait for the shard the key was moved to
+	shard.waiters.wakeAll()
+}
+
+// completeResize evacuates all remaining shards of t and makes the next table of t the current table.
+func (m *Map) completeResize(t *shardTable) {
+	next := t.next.Load()
+	if next == nil {
+		return
+	}
+
+	for index := range t.shards {
+		m.evacuate(t, uint(index))
+	}
+
+	if m.table.CompareAndSwap(t, next) {
+		for _, shard := range t.shards {
+			if counter, ok := shard.Shard.(EvictionCounter); ok {
+				m.retiredEvictions.Add(counter.Evictions())
+			}
+
+			m.retiredStats.add(shard.stats)
+		}
+	}
+}
+
+// stableTable completes a resize in progress and returns the current table. Operations on the whole Map use
+// it to visit every entry exactly once.
+func (m *Map) stableTable() *shardTable {
+	for {
+		t := m.table.Load()
+		if t.next.Load() == nil {
+			return t
+		}
+
+		m.completeResize(t)
+	}
+}
+
+// Resize changes the number of shards to count. The entries are moved to the new shards incrementally:
+// every write moves the shard it modifies and a background goroutine moves the remaining shards.
+// Reads and writes stay available while the Map is resized, only writers of the shard that is currently moved
+// have to wait. Operations on the whole Map, like Count or Snapshot, complete the resize first.
+//
+// A resize that is still in progress is completed before the next one starts. If the Map is limited by
+// WithMaxEntries, the capacity is split between the new shards and entries that do not fit are evicted.
+// Resize returns ErrInvalidShardCount if count is less than 1.
+func (m *Map) Resize(count int) error {
+	if count < 1 {
+		return ErrInvalidShardCount
+	}
+
+	m.resizeMu.Lock()
+	defer m.resizeMu.Unlock()
+
+	if m.powerOfTwo {
+		count = int(nextPowerOfTwo(uint(count)))
+	}
+
+	t := m.stableTable()
+	if t.count == uint(count) {
+		return nil
+	}
+
+	t.next.Store(m.newTable(uint(count)))
+
+	m.resizeWG.Add(1)
+
+	go func() {
+		defer m.resizeWG.Done()
+
+		m.completeResize(t)
+	}()
+
+	return nil
+}
+
+// ShardCount returns the number of shards. While the Map is resized, it returns the new number of shards.
+func (m *Map) ShardCount() int {
+	t := m.table.Load()
+	if next := t.next.Load(); next != nil {
+		return int(next.count)
+	}
+
+	return int(t.count)
+}
+
+// grow doubles the number of shards in the background until no shard holds more entries than allowed by
+// WithAutoGrow or the shard limit is reached.
+func (m *Map) grow() {
+	if t := m.table.Load(); t.next.Load() != nil || t.count >= m.growMaxShardCount {
+		return
+	}
+
+	// Only one goroutine grows the Map
+	if !m.growing.CompareAndSwap(false, true) {
+		return
+	}
+
+	m.resizeWG.Add(1)
+
+	go func() {
+		defer m.resizeWG.Done()
+		defer m.growing.Store(false)
+
+		for t := m.stableTable(); t.count < m.growMaxShardCount && t.exceeds(m.growThreshold); t = m.stableTable() {
+			count := t.count * 2 //nolint:gomnd
+			if count > m.growMaxShardCount {
+				count = m.growMaxShardCount
+			}
+
+			_ = m.Resize(int(count))
+		}
+	}()
+}
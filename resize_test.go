@@ -0,0 +1,141 @@
+package shardedmap_test
+
+import (
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+var resizeTestProviders = []shardedmap.ShardProviderFunc{ //nolint:gochecknoglobals
+	shardedmap.NewMutexShard,
+	shardedmap.NewAtomicShard,
+	shardedmap.NewLRUShard,
+}
+
+func TestResize(t *testing.T) {
+	for _, provider := range resizeTestProviders {
+		instance := shardedmap.New(shardedmap.WithShardCount(2), shardedmap.WithCustomShardProvider(provider))
+
+		for i := 0; i < 1000; i++ {
+			assert.NoError(t, instance.Set(fmt.Sprint(i), i))
+		}
+
+		assert.NoError(t, instance.Resize(16))
+		assert.Equal(t, 16, instance.ShardCount())
+
+		// Entries are readable while the shards are moved
+		for i := 0; i < 1000; i++ {
+			assert.Equal(t, i, instance.MustGet(fmt.Sprint(i)))
+		}
+
+		assert.Equal(t, 1000, instance.Count())
+		assert.Len(t, instance.All(), 1000)
+
+		assert.NoError(t, instance.Resize(3))
+		instance.Remove("0")
+		assert.Equal(t, 999, instance.Count())
+		assert.Equal(t, 3, instance.ShardCount())
+
+		assert.NoError(t, instance.Close())
+	}
+}
+
+func TestResizeWithConsistentSelector(t *testing.T) {
+	instance := shardedmap.New(shardedmap.WithShardCount(3), shardedmap.WithShardSelector(shardedmap.JumpHashSelector{}))
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, instance.Set(fmt.Sprint(i), i))
+	}
+
+	assert.NoError(t, instance.Resize(5))
+	assert.Equal(t, 100, instance.Count())
+
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, i, instance.MustGet(fmt.Sprint(i)))
+	}
+}
+
+func TestResizeInvalidShardCount(t *testing.T) {
+	instance := shardedmap.New()
+
+	assert.ErrorIs(t, instance.Resize(0), shardedmap.ErrInvalidShardCount)
+	assert.Equal(t, int(shardedmap.DefaultShardCount), instance.ShardCount())
+}
+
+func TestResizeWithConcurrentReadersAndWriters(t *testing.T) {
+	for _, provider := range resizeTestProviders {
+		instance := shardedmap.New(shardedmap.WithShardCount(1), shardedmap.WithCustomShardProvider(provider))
+
+		const writers, keysPerWriter = 4, 200
+
+		var wg sync.WaitGroup
+
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+
+			go func(w int) {
+				defer wg.Done()
+
+				for i := 0; i < keysPerWriter; i++ {
+					key := fmt.Sprintf("%d-%d", w, i)
+
+					assert.NoError(t, instance.Set(key, i))
+					assert.Equal(t, i, instance.MustGet(key))
+
+					_, err := instance.Upsert(key, func(old interface{}, exists bool) interface{} {
+						return old.(int) + 1 //nolint:forcetypeassert
+					})
+					assert.NoError(t, err)
+				}
+			}(w)
+		}
+
+		for _, count := range []int{4, 16, 7, 32} {
+			assert.NoError(t, instance.Resize(count))
+		}
+
+		wg.Wait()
+
+		assert.Equal(t, writers*keysPerWriter, instance.Count())
+
+		for w := 0; w < writers; w++ {
+			for i := 0; i < keysPerWriter; i++ {
+				assert.Equal(t, i+1, instance.MustGet(fmt.Sprintf("%d-%d", w, i)))
+			}
+		}
+
+		assert.NoError(t, instance.Close())
+	}
+}
+
+func TestResizeKeepsConsistentSnapshots(t *testing.T) {
+	instance := shardedmap.New(shardedmap.WithShardCount(2))
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, instance.Set(fmt.Sprint(i), i))
+	}
+
+	assert.NoError(t, instance.Resize(8))
+
+	assert.Equal(t, 100, instance.Snapshot(shardedmap.SnapshotConsistent).Count())
+	assert.Equal(t, 100, instance.Snapshot(shardedmap.SnapshotPerShard).Count())
+}
+
+func TestAutoGrow(t *testing.T) {
+	instance := shardedmap.New(shardedmap.WithShardCount(1), shardedmap.WithAutoGrow(10, 8))
+	defer instance.Close()
+
+	for i := 0; i < 500; i++ {
+		assert.NoError(t, instance.Set(fmt.Sprint(i), i))
+	}
+
+	assert.Eventually(t, func() bool { return instance.ShardCount() == 8 }, time.Second, time.Millisecond)
+	assert.Equal(t, 500, instance.Count())
+
+	for i := 0; i < 500; i++ {
+		assert.Equal(t, i, instance.MustGet(fmt.Sprint(i)))
+	}
+}
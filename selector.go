@@ -0,0 +1,69 @@
+package shardedmap
+
+// ShardSelector defines how a Map distributes key hashes to its shards.
+type ShardSelector interface {
+	// SelectShard returns the index of the shard for keyHash in a Map of shardCount shards.
+	// The result must be less than shardCount and must only depend on the arguments.
+	SelectShard(keyHash uint, shardCount uint) uint
+}
+
+// ModuloSelector selects the shard by keyHash % shardCount. It is the fastest selector, but nearly all keys are
+// assigned to a different shard if the shard count changes.
+type ModuloSelector struct{}
+
+// SelectShard see: interfaces.ShardSelector.
+func (ModuloSelector) SelectShard(keyHash uint, shardCount uint) uint {
+	return keyHash % shardCount
+}
+
+// JumpHashSelector selects the shard using the jump consistent hash of John Lamping and Eric Veach.
+// If the shard count grows from n to m, only (m-n)/m of the keys are assigned to a different shard and all of
+// them move to the new shards. It runs in O(log n) time.
+type JumpHashSelector struct{}
+
+// SelectShard see: interfaces.ShardSelector.
+func (JumpHashSelector) SelectShard(keyHash uint, shardCount uint) uint {
+	key := uint64(keyHash)
+
+	var b, j int64 = -1, 0
+
+	for j < int64(shardCount) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1))) //nolint:gomnd
+	}
+
+	return uint(b)
+}
+
+// RendezvousSelector selects the shard with the highest score for keyHash (highest random weight hashing).
+// Like JumpHashSelector, it only reassigns a minimal fraction of keys if the shard count changes. Additionally,
+// removing the last shard only moves the keys of this shard. It runs in O(n) time, so it suits small shard counts.
+type RendezvousSelector struct{}
+
+// SelectShard see: interfaces.ShardSelector.
+func (RendezvousSelector) SelectShard(keyHash uint, shardCount uint) uint {
+	var (
+		selected uint
+		maxScore uint64
+	)
+
+	for i := uint(0); i < shardCount; i++ {
+		if score := mix64(uint64(keyHash) ^ mix64(uint64(i))); i == 0 || score > maxScore {
+			selected, maxScore = i, score
+		}
+	}
+
+	return selected
+}
+
+// mix64 is the finalizer of SplitMix64. It spreads every input bit across all output bits.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
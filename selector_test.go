@@ -0,0 +1,88 @@
+package shardedmap_test
+
+import (
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+var consistentSelectors = []shardedmap.ShardSelector{ //nolint:gochecknoglobals
+	shardedmap.JumpHashSelector{},
+	shardedmap.RendezvousSelector{},
+}
+
+func TestMapRunSuiteWithSelectors(t *testing.T) {
+	for _, selector := range append(consistentSelectors, shardedmap.ModuloSelector{}) {
+		suite.Run(t, NewMapTestSuite(
+			shardedmap.New(shardedmap.WithShardCount(8), shardedmap.WithShardSelector(selector)),
+		))
+	}
+}
+
+func TestSelectorsDistributeKeys(t *testing.T) {
+	const shardCount, keys = 16, 16000
+
+	for _, selector := range append(consistentSelectors, shardedmap.ModuloSelector{}) {
+		perShard := make([]int, shardCount)
+
+		for i := 0; i < keys; i++ {
+			index := selector.SelectShard(shardedmap.HashFnv1a64(fmt.Sprint(i)), shardCount)
+			if !assert.Less(t, index, uint(shardCount)) {
+				return
+			}
+
+			perShard[index]++
+		}
+
+		for _, count := range perShard {
+			assert.InDelta(t, keys/shardCount, count, keys/shardCount*0.2, "%T", selector)
+		}
+
+		assert.Equal(t, uint(0), selector.SelectShard(12345, 1))
+	}
+}
+
+func TestConsistentSelectorsMoveFewKeys(t *testing.T) {
+	const keys = 10000
+
+	moved := func(selector shardedmap.ShardSelector, from, to uint) int {
+		var count int
+
+		for i := 0; i < keys; i++ {
+			keyHash := shardedmap.HashFnv1a64(fmt.Sprint(i))
+			oldIndex, newIndex := selector.SelectShard(keyHash, from), selector.SelectShard(keyHash, to)
+
+			if oldIndex != newIndex {
+				count++
+
+				// Keys only move to the added shard
+				assert.Equal(t, to-1, newIndex, "%T", selector)
+			}
+		}
+
+		return count
+	}
+
+	for _, selector := range consistentSelectors {
+		// Ideally 1/11 of the keys move
+		assert.Less(t, moved(selector, 10, 11), keys*2/11, "%T", selector)
+	}
+}
+
+func TestModuloSelectorMovesMostKeys(t *testing.T) {
+	var (
+		selector shardedmap.ModuloSelector
+		moved    int
+	)
+
+	for i := 0; i < 1000; i++ {
+		keyHash := shardedmap.HashFnv1a64(fmt.Sprint(i))
+		if selector.SelectShard(keyHash, 10) != selector.SelectShard(keyHash, 11) {
+			moved++
+		}
+	}
+
+	assert.Greater(t, moved, 800)
+}
@@ -3,34 +3,363 @@ package shardedmap
 // ShardProviderFunc defines a function that is used to create Shards while initializing a Map.
 type ShardProviderFunc func() Shard
 
-type ShardDataMap map[uint]ShardTuple
+// ShardDataMap holds the data of a shard. Tuples are indexed by the hash of their key and
+// tuples with colliding key hashes share a ShardBucket.
+type ShardDataMap map[uint]ShardBucket
 
 type ShardTuple interface {
 	GetKey() string
 	GetValue() interface{}
+	GetExpiry() int64
 }
 
 // Shard defines the interface that can be passed to map.
+//
+// Shards receive the key hash used for indexing alongside the original key. Different keys may
+// produce the same hash, so implementations must compare the key before returning or modifying
+// a tuple. ShardDataMap and ShardBucket implement this for map based shards.
+//
+// Expired tuples must be treated as absent by Get and Has. They are deleted by RemoveExpired.
+//
+// Errors returned by shards are KeyErrors wrapping ErrNotFound, ErrExpired or ErrCapacity.
 type Shard interface {
 
-	// All returns all contained data as KVMap.
-	All() ShardDataMap
+	// Snapshot returns a point-in-time view of all contained data that is not affected by later writes.
+	// Implementations return a copy or an immutable version of their data. The result must not be modified.
+	Snapshot() ShardDataMap
 
-	// Get returns a value from Collection.
-	Get(uint) (interface{}, error)
+	// Get returns a value from Collection or a KeyError wrapping ErrNotFound or ErrExpired.
+	Get(keyHash uint, key string) (interface{}, error)
 
 	// Set sets a value to Collection.
-	Set(uint, ShardTuple)
+	Set(keyHash uint, tuple ShardTuple) error
 
 	// Has checks the existence of a key/value in Collection.
-	Has(uint) bool
+	Has(keyHash uint, key string) bool
 
 	// Remove removes an element by key from Collection.
-	Remove(uint)
+	Remove(keyHash uint, key string)
 
-	// Count returns the count of elements in Collection.
+	// Count returns the count of elements in Collection including expired elements that are not removed yet.
 	Count() uint
 
+	// RemoveExpired removes all elements that are expired at now and returns how many were removed.
+	RemoveExpired(now int64) uint
+
 	// Clear resets all data in Collection.
 	Clear()
+
+	// Compute atomically updates the tuple for key with the result of fn and returns the stored tuple.
+	Compute(keyHash uint, key string, fn ComputeFunc) (ShardTuple, error)
+}
+
+// ComputeFunc receives the tuple currently stored for a key or nil if the key is absent or expired.
+// If write is false the shard is left unchanged. Otherwise next replaces the current tuple or, if next is nil,
+// the key is removed.
+type ComputeFunc func(current ShardTuple) (next ShardTuple, write bool)
+
+// Lookup returns the tuple stored for key.
+func (d ShardDataMap) Lookup(keyHash uint, key string) (ShardTuple, bool) {
+	bucket, ok := d[keyHash]
+	if !ok {
+		return nil, false
+	}
+
+	return bucket.Find(key)
+}
+
+// Store adds or replaces the tuple for its key and returns the replaced tuple.
+func (d ShardDataMap) Store(keyHash uint, tuple ShardTuple) (previous ShardTuple, replaced bool) {
+	d[keyHash], previous = d[keyHash].With(tuple)
+
+	return previous, previous != nil
+}
+
+// Delete removes the tuple for key and returns it.
+func (d ShardDataMap) Delete(keyHash uint, key string) (ShardTuple, bool) {
+	bucket, ok := d[keyHash]
+	if !ok {
+		return nil, false
+	}
+
+	bucket, removed, ok := bucket.Without(key)
+	if !ok {
+		return nil, false
+	}
+
+	if bucket.Len() == 0 {
+		delete(d, keyHash)
+	} else {
+		d[keyHash] = bucket
+	}
+
+	return removed, true
+}
+
+// Clone returns a copy of the map. Buckets are values and can be shared by both maps.
+func (d ShardDataMap) Clone() ShardDataMap {
+	clone := make(ShardDataMap, len(d))
+	for keyHash, bucket := range d {
+		clone[keyHash] = bucket
+	}
+
+	return clone
+}
+
+// DeleteExpired removes all tuples that are expired at now and returns them.
+func (d ShardDataMap) DeleteExpired(now int64) []ShardTuple {
+	var expired []ShardTuple
+
+	d.Range(func(keyHash uint, tuple ShardTuple) bool {
+		if IsExpired(tuple, now) {
+			d.Delete(keyHash, tuple.GetKey())
+			expired = append(expired, tuple)
+		}
+
+		return true
+	})
+
+	return expired
+}
+
+// computeResult describes the outcome of ShardDataMap.compute.
+type computeResult struct {
+	// tuple is the tuple stored for the key after compute.
+	tuple ShardTuple
+	// delta is the change of the number of tuples in the map.
+	delta int
+	// dropped is a tuple that left the map because it was removed or replaced after it expired.
+	dropped ShardTuple
+	reason  EvictionReason
+	err     error
+}
+
+// compute implements Shard.Compute for shards that store their data in a ShardDataMap.
+// The current tuple is read from d. Changes are applied to the map returned by writable, which is only
+// called if fn requests a write. If full is true, adding a new key fails with ErrCapacity.
+func (d ShardDataMap) compute(
+	keyHash uint,
+	key string,
+	fn ComputeFunc,
+	writable func() ShardDataMap,
+	full bool,
+) computeResult {
+	res := computeResult{} //nolint:exhaustivestruct
+
+	current, exists := d.Lookup(keyHash, key)
+	if exists && isExpiredNow(current) {
+		res.dropped, res.reason = current, EvictionReasonExpired
+		current = nil
+	}
+
+	next, write := fn(current)
+
+	switch {
+	case !write:
+		res.dropped = nil
+		res.tuple = current
+	case next != nil && !exists && full:
+		res.dropped = nil
+		res.err = newKeyError(key, ErrCapacity)
+	case next != nil:
+		if _, replaced := writable().Store(keyHash, next); !replaced {
+			res.delta = 1
+		}
+
+		res.tuple = next
+	case exists:
+		writable().Delete(keyHash, key)
+		res.delta = -1
+
+		if res.dropped == nil {
+			res.dropped, res.reason = current, EvictionReasonRemoved
+		}
+	}
+
+	return res
+}
+
+// Range calls fn for every tuple in the map until fn returns false.
+func (d ShardDataMap) Range(fn func(keyHash uint, tuple ShardTuple) bool) {
+	for keyHash, bucket := range d {
+		for i := 0; i < bucket.Len(); i++ {
+			if !fn(keyHash, bucket.At(i)) {
+				return
+			}
+		}
+	}
+}
+
+// ShardBucket holds all tuples that share a key hash.
+//
+// Buckets are values. Methods returning a ShardBucket never modify the receiver, so a bucket
+// can safely be shared between copies of a ShardDataMap.
+type ShardBucket struct {
+	head     ShardTuple
+	overflow []ShardTuple
+}
+
+// Len returns the number of tuples in the bucket.
+func (b ShardBucket) Len() int {
+	if b.head == nil {
+		return 0
+	}
+
+	return 1 + len(b.overflow)
+}
+
+// At returns the tuple at position i.
+func (b ShardBucket) At(i int) ShardTuple {
+	if i == 0 {
+		return b.head
+	}
+
+	return b.overflow[i-1]
+}
+
+// Find returns the tuple stored for key.
+func (b ShardBucket) Find(key string) (ShardTuple, bool) {
+	if i := b.index(key); i >= 0 {
+		return b.At(i), true
+	}
+
+	return nil, false
+}
+
+// With returns a bucket containing tuple in place of any tuple with the same key, along with the replaced tuple.
+func (b ShardBucket) With(tuple ShardTuple) (bucket ShardBucket, replaced ShardTuple) {
+	switch i := b.index(tuple.GetKey()); {
+	case b.head == nil:
+		return ShardBucket{head: tuple, overflow: nil}, nil
+	case i == 0:
+		return ShardBucket{head: tuple, overflow: b.overflow}, b.head
+	case i > 0:
+		overflow := make([]ShardTuple, len(b.overflow))
+		copy(overflow, b.overflow)
+		overflow[i-1] = tuple
+
+		return ShardBucket{head: b.head, overflow: overflow}, b.overflow[i-1]
+	default:
+		overflow := make([]ShardTuple, len(b.overflow), len(b.overflow)+1)
+		copy(overflow, b.overflow)
+
+		return ShardBucket{head: b.head, overflow: append(overflow, tuple)}, nil
+	}
+}
+
+// Without returns a bucket that no longer contains the tuple for key, along with the removed tuple.
+func (b ShardBucket) Without(key string) (bucket ShardBucket, removed ShardTuple, ok bool) {
+	i := b.index(key)
+	if i < 0 {
+		return b, nil, false
+	}
+
+	removed = b.At(i)
+
+	if len(b.overflow) == 0 {
+		return ShardBucket{head: nil, overflow: nil}, removed, true
+	}
+
+	rest := make([]ShardTuple, 0, len(b.overflow))
+
+	for j := 0; j < b.Len(); j++ {
+		if j != i {
+			rest = append(rest, b.At(j))
+		}
+	}
+
+	if len(rest) == 1 {
+		return ShardBucket{head: rest[0], overflow: nil}, removed, true
+	}
+
+	return ShardBucket{head: rest[0], overflow: rest[1:]}, removed, true
+}
+
+func (b ShardBucket) index(key string) int {
+	for i := 0; i < b.Len(); i++ {
+		if b.At(i).GetKey() == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ShardConfig contains the settings a Map passes to its shards.
+type ShardConfig struct {
+	// MaxEntries is the maximum number of entries the shard should hold. 0 means unlimited.
+	// Shards that do not evict entries reject new keys with ErrCapacity once they are full.
+	MaxEntries uint
+
+	// OnEvict must be called for every entry that is removed, cleared, expired or evicted. It may be nil.
+	OnEvict EvictionHandler
+}
+
+// ConfigurableShard is implemented by shards that accept a ShardConfig.
+// Map calls Configure once after creating the shard with its ShardProviderFunc.
+type ConfigurableShard interface {
+	Configure(cfg ShardConfig)
+}
+
+// TupleGetter is implemented by shards that return the stored tuple of a key. Map uses it for GetWithVersion.
+// For other shards, the tuple is read with Compute.
+type TupleGetter interface {
+	// GetTuple returns the tuple for key or a KeyError wrapping ErrNotFound or ErrExpired. It behaves like Get.
+	GetTuple(keyHash uint, key string) (ShardTuple, error)
+}
+
+// getTuple returns the tuple for key from shard, see TupleGetter.
+func getTuple(shard Shard, keyHash uint, key string) (ShardTuple, error) {
+	if getter, ok := shard.(TupleGetter); ok {
+		return getter.GetTuple(keyHash, key)
+	}
+
+	// Without a write, Compute cannot fail
+	tuple, _ := shard.Compute(keyHash, key, func(current ShardTuple) (ShardTuple, bool) {
+		return current, false
+	})
+	if tuple == nil {
+		return nil, newKeyError(key, ErrNotFound)
+	}
+
+	return tuple, nil
+}
+
+// removeKey removes key from shard and reports whether an entry that was not expired was removed. Unlike
+// Shard.Remove, expired entries are passed to the EvictionHandler with EvictionReasonExpired.
+func removeKey(shard Shard, keyHash uint, key string) bool {
+	removed := false
+
+	// Removing a key cannot fail
+	_, _ = shard.Compute(keyHash, key, func(current ShardTuple) (ShardTuple, bool) {
+		removed = current != nil
+
+		return nil, true
+	})
+
+	return removed
+}
+
+// BatchShard is implemented by shards that process several keys under a single lock. Map uses it for SetMany,
+// GetMany, HasMany and RemoveMany. For other shards, the keys are processed one by one.
+// In all methods, keyHashes[i] is the hash of the i-th key.
+type BatchShard interface {
+	// GetMany calls fn with the position and value of every key that is present and not expired.
+	// fn is called while the shard is locked and must not access the shard.
+	GetMany(keyHashes []uint, keys []string, fn func(i int, value interface{}))
+
+	// SetMany sets all tuples like Set. It returns nil if all tuples were set. Otherwise, it returns a slice
+	// holding the error of every tuple that was not set at the position of the tuple.
+	SetMany(keyHashes []uint, tuples []ShardTuple) []error
+
+	// RemoveMany removes all keys like Remove and calls fn with the position of every removed entry that was not
+	// expired. Expired entries are passed to the EvictionHandler with EvictionReasonExpired.
+	// fn is called while the shard is locked and must not access the shard.
+	RemoveMany(keyHashes []uint, keys []string, fn func(i int))
+}
+
+// EvictionCounter is implemented by shards that evict entries to stay within their capacity.
+type EvictionCounter interface {
+	// Evictions returns the number of entries evicted because of capacity limits.
+	Evictions() uint64
 }
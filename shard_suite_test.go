@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/suite"
 	"math/rand"
 	"reflect"
+	"time"
 )
 
 func pickRandomKeyFromDataSet(m map[string]interface{}) string {
@@ -37,24 +38,78 @@ func (s *ShardTestSuite) SetupTest() {
 
 func (s *ShardTestSuite) TestGet() {
 	k := pickRandomKeyFromDataSet(s.testDataSet)
-	v, err := s.instance.Get(shardedmap.HashFnv1a64(k))
+	v, err := s.instance.Get(shardedmap.HashFnv1a64(k), k)
 	s.NoError(err)
 	s.Equal(s.testDataSet[k], v)
 
 	// Check error
-	_, err = s.instance.Get(0)
+	_, err = s.instance.Get(0, "____not_existing_key")
+	s.ErrorIs(err, shardedmap.ErrNotFound)
+
+	// Same hash but a different key must not match
+	_, err = s.instance.Get(shardedmap.HashFnv1a64(k), "____not_existing_key")
 	s.Error(err)
 }
 
 func (s *ShardTestSuite) TestHas() {
 	k := pickRandomKeyFromDataSet(s.testDataSet)
-	s.True(s.instance.Has(shardedmap.HashFnv1a64(k)))
+	s.True(s.instance.Has(shardedmap.HashFnv1a64(k), k))
 }
 
 func (s *ShardTestSuite) TestSet() {
 	keyHash := shardedmap.HashFnv1a64("key")
 	s.instance.Set(keyHash, shardedmap.NewTuple("key", "value"))
-	s.True(s.instance.Has(keyHash))
+	s.True(s.instance.Has(keyHash, "key"))
+}
+
+func (s *ShardTestSuite) TestCollidingKeys() {
+	const keyHash = 42
+
+	s.instance.Set(keyHash, shardedmap.NewTuple("____first", 1))
+	s.instance.Set(keyHash, shardedmap.NewTuple("____second", 2))
+	s.instance.Set(keyHash, shardedmap.NewTuple("____third", 3))
+	s.instance.Set(keyHash, shardedmap.NewTuple("____second", 22))
+
+	s.Equal(len(s.testDataSet)+3, int(s.instance.Count()))
+
+	for key, expected := range map[string]int{"____first": 1, "____second": 22, "____third": 3} {
+		v, err := s.instance.Get(keyHash, key)
+		s.NoError(err)
+		s.Equal(expected, v)
+	}
+
+	s.instance.Remove(keyHash, "____first")
+	s.False(s.instance.Has(keyHash, "____first"))
+	s.True(s.instance.Has(keyHash, "____second"))
+	s.True(s.instance.Has(keyHash, "____third"))
+
+	s.instance.Remove(keyHash, "not_existing_key")
+	s.Equal(len(s.testDataSet)+2, int(s.instance.Count()))
+}
+
+func (s *ShardTestSuite) TestExpiry() {
+	past := time.Now().Add(-time.Second).UnixNano()
+	future := time.Now().Add(time.Hour).UnixNano()
+
+	expiredHash := shardedmap.HashFnv1a64("expired")
+	s.instance.Set(expiredHash, shardedmap.NewTupleWithExpiry("expired", 1, past))
+
+	validHash := shardedmap.HashFnv1a64("valid")
+	s.instance.Set(validHash, shardedmap.NewTupleWithExpiry("valid", 2, future))
+
+	s.False(s.instance.Has(expiredHash, "expired"))
+	_, err := s.instance.Get(expiredHash, "expired")
+	s.Error(err)
+
+	v, err := s.instance.Get(validHash, "valid")
+	s.NoError(err)
+	s.Equal(2, v)
+
+	// Expired entries are counted until they are removed
+	s.Equal(len(s.testDataSet)+2, int(s.instance.Count()))
+	s.Equal(uint(1), s.instance.RemoveExpired(time.Now().UnixNano()))
+	s.Equal(len(s.testDataSet)+1, int(s.instance.Count()))
+	s.True(s.instance.Has(validHash, "valid"))
 }
 
 func (s *ShardTestSuite) TestCount() {
@@ -62,9 +117,10 @@ func (s *ShardTestSuite) TestCount() {
 }
 
 func (s *ShardTestSuite) TestRemove() {
-	keyHash := shardedmap.HashFnv1a64(pickRandomKeyFromDataSet(s.testDataSet))
-	s.instance.Remove(keyHash)
-	s.False(s.instance.Has(keyHash))
+	key := pickRandomKeyFromDataSet(s.testDataSet)
+	keyHash := shardedmap.HashFnv1a64(key)
+	s.instance.Remove(keyHash, key)
+	s.False(s.instance.Has(keyHash, key))
 }
 
 func (s *ShardTestSuite) TestClear() {
@@ -72,13 +128,31 @@ func (s *ShardTestSuite) TestClear() {
 	s.Equal(0, int(s.instance.Count()))
 }
 
-func (s *ShardTestSuite) TestAll() {
+func (s *ShardTestSuite) TestSnapshot() {
 	// Convert back into map[string]interface{}
 	shardData := make(map[string]interface{}, len(s.testDataSet))
-	for _, v := range s.instance.All() {
+	s.instance.Snapshot().Range(func(_ uint, v shardedmap.ShardTuple) bool {
 		shardData[v.GetKey()] = v.GetValue()
-	}
+
+		return true
+	})
 
 	s.Equal(s.testDataSet, shardData)
 	s.Equal(len(s.testDataSet), len(shardData))
 }
+
+func (s *ShardTestSuite) TestSnapshotIsNotAffectedByWrites() {
+	snapshot := s.instance.Snapshot()
+	key := pickRandomKeyFromDataSet(s.testDataSet)
+
+	s.instance.Set(shardedmap.HashFnv1a64(key), shardedmap.NewTuple(key, "changed"))
+	s.instance.Set(shardedmap.HashFnv1a64("____new_key"), shardedmap.NewTuple("____new_key", "new"))
+	s.instance.Clear()
+
+	tuple, ok := snapshot.Lookup(shardedmap.HashFnv1a64(key), key)
+	s.True(ok)
+	s.Equal(s.testDataSet[key], tuple.GetValue())
+
+	_, ok = snapshot.Lookup(shardedmap.HashFnv1a64("____new_key"), "____new_key")
+	s.False(ok)
+}
@@ -0,0 +1,120 @@
+package shardedmap
+
+import (
+	"time"
+)
+
+// SnapshotMode defines the consistency of a Snapshot.
+type SnapshotMode int
+
+const (
+	// SnapshotPerShard takes the snapshot of one shard after another. Each shard is captured at a single
+	// point in time, but writes to other shards may happen in between. Writers are never blocked.
+	SnapshotPerShard SnapshotMode = iota
+	// SnapshotConsistent captures all shards at the same point in time. Writers are blocked while the
+	// shard snapshots are taken, so it must not be used from an eviction callback.
+	SnapshotConsistent
+)
+
+// Snapshot is a read-only view of a Map that is not affected by later writes.
+// Entries that were expired when the snapshot was taken are not part of it.
+type Snapshot struct {
+	shards []ShardDataMap
+	now    int64
+}
+
+// Snapshot returns a snapshot of the map taken in the given mode. A resize in progress is completed first.
+func (m *Map) Snapshot(mode SnapshotMode) *Snapshot {
+	t := m.stableTable()
+
+	if mode == SnapshotConsistent {
+		t = m.lockTable()
+		defer unlockTable(t)
+	}
+
+	snapshot := &Snapshot{shards: make([]ShardDataMap, len(t.shards)), now: time.Now().UnixNano()}
+
+	for i, shard := range t.shards {
+		snapshot.shards[i] = shard.Snapshot()
+	}
+
+	return snapshot
+}
+
+// lockTable locks the gates of all shards of the current table for writing and returns the table. No shard
+// can be evacuated while its gate is locked. If a resize evacuated a shard before all gates were acquired,
+// the resize is completed and the gates of the new table are locked.
+func (m *Map) lockTable() *shardTable {
+	for {
+		t := m.stableTable()
+
+		// Gates are always acquired in index order
+		for _, shard := range t.shards {
+			shard.gate.Lock()
+		}
+
+		if !t.hasEvacuated() {
+			return t
+		}
+
+		unlockTable(t)
+	}
+}
+
+func unlockTable(t *shardTable) {
+	for _, shard := range t.shards {
+		shard.gate.Unlock()
+	}
+}
+
+// rangeTuples calls fn for every tuple until fn returns false.
+func (s *Snapshot) rangeTuples(fn func(tuple ShardTuple) bool) {
+	for _, data := range s.shards {
+		proceed := true
+
+		data.Range(func(_ uint, tuple ShardTuple) bool {
+			if !IsExpired(tuple, s.now) {
+				proceed = fn(tuple)
+			}
+
+			return proceed
+		})
+
+		if !proceed {
+			return
+		}
+	}
+}
+
+// Range calls fn for every key and value until fn returns false.
+func (s *Snapshot) Range(fn func(key string, value interface{}) bool) {
+	s.rangeTuples(func(tuple ShardTuple) bool {
+		return fn(tuple.GetKey(), tuple.GetValue())
+	})
+}
+
+// Count returns the number of entries in the snapshot.
+func (s *Snapshot) Count() int {
+	var count int
+
+	s.rangeTuples(func(ShardTuple) bool {
+		count++
+
+		return true
+	})
+
+	return count
+}
+
+// All returns a flat map of all keys and values in the snapshot.
+func (s *Snapshot) All() map[string]interface{} {
+	allData := make(map[string]interface{})
+
+	s.Range(func(key string, value interface{}) bool {
+		allData[key] = value
+
+		return true
+	})
+
+	return allData
+}
@@ -0,0 +1,84 @@
+package shardedmap_test
+
+import (
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func (s *MapTestSuite) TestSnapshot() {
+	for _, mode := range []shardedmap.SnapshotMode{shardedmap.SnapshotPerShard, shardedmap.SnapshotConsistent} {
+		snapshot := s.instance.Snapshot(mode)
+
+		s.instance.Set("____new_key", "value")
+		s.instance.Remove(pickRandomKeyFromDataSet(s.testDataSet))
+
+		s.Equal(len(s.testDataSet), snapshot.Count())
+		s.Equal(s.testDataSet, snapshot.All())
+
+		var visited int
+
+		snapshot.Range(func(key string, value interface{}) bool {
+			visited++
+
+			return visited < 3
+		})
+		if len(s.testDataSet) >= 3 {
+			s.Equal(3, visited)
+		} else {
+			s.Equal(len(s.testDataSet), visited)
+		}
+
+		s.instance.Remove("____new_key")
+
+		for k, v := range s.testDataSet {
+			s.instance.Set(k, v)
+		}
+	}
+}
+
+// Run with -race to detect iterations over data that is modified concurrently.
+func TestIterationWhileWriting(t *testing.T) {
+	for _, provider := range []shardedmap.ShardProviderFunc{
+		shardedmap.NewMutexShard,
+		shardedmap.NewAtomicShard,
+		shardedmap.NewLRUShard,
+	} {
+		instance := shardedmap.New(shardedmap.WithShardCount(4), shardedmap.WithCustomShardProvider(provider))
+
+		var wg sync.WaitGroup
+
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 1000; i++ {
+				instance.Set(fmt.Sprintf("key-%d", i%50), i)
+				instance.Remove(fmt.Sprintf("key-%d", (i+25)%50))
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 50; i++ {
+				for range instance.Range() { //nolint:revive
+				}
+
+				_ = instance.All()
+				_ = instance.Snapshot(shardedmap.SnapshotConsistent).Count()
+
+				instance.RangeWithCallback(func(key string, value interface{}) interface{} {
+					return nil
+				})
+			}
+		}()
+
+		wg.Wait()
+
+		assert.Equal(t, instance.Count(), instance.Snapshot(shardedmap.SnapshotConsistent).Count())
+	}
+}
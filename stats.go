@@ -0,0 +1,146 @@
+package shardedmap
+
+import (
+	"expvar"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// ShardStats holds the statistics of a single shard or, in Stats.Total, of the whole Map.
+// The counters are only maintained if the Map was created WithStats. Entries and Evictions are always reported.
+type ShardStats struct {
+	// Entries is the number of entries, including expired entries that were not removed yet.
+	Entries int `json:"entries"`
+	// Hits and Misses count the lookups of Get, Has and GetOrSet that found or did not find the key.
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// Sets and Removes count the entries stored and removed by the operations of the Map. Removing a key that is
+	// absent or expired is not counted.
+	Sets    uint64 `json:"sets"`
+	Removes uint64 `json:"removes"`
+	// Evictions counts the entries evicted because of capacity limits.
+	Evictions uint64 `json:"evictions"`
+	// LockWait is the total time writers waited to lock the shard.
+	LockWait time.Duration `json:"lock_wait_ns"`
+}
+
+// Stats holds the statistics of a Map returned by Map.Stats.
+type Stats struct {
+	// Shards holds the statistics of every shard in shard order.
+	Shards []ShardStats `json:"shards"`
+	// Total sums up all shards. The counters include shards that were replaced by Resize.
+	Total ShardStats `json:"total"`
+}
+
+// shardStats holds the counters of a shard. It is padded, so counters of different shards do not share a
+// cache line. All methods are no-ops on a nil receiver, which is used if statistics are disabled.
+type shardStats struct {
+	shardCounters
+	_ [cacheLineSize - unsafe.Sizeof(shardCounters{})%cacheLineSize]byte //nolint:exhaustivestruct
+}
+
+type shardCounters struct {
+	hits     atomic.Uint64
+	misses   atomic.Uint64
+	sets     atomic.Uint64
+	removes  atomic.Uint64
+	lockWait atomic.Int64
+}
+
+func (s *shardStats) lookup(found bool) {
+	if s == nil {
+		return
+	}
+
+	if found {
+		s.hits.Add(1)
+	} else {
+		s.misses.Add(1)
+	}
+}
+
+func (s *shardStats) set() {
+	if s != nil {
+		s.sets.Add(1)
+	}
+}
+
+func (s *shardStats) remove() {
+	s.removeMany(1)
+}
+
+func (s *shardStats) removeMany(n int) {
+	if s != nil && n > 0 {
+		s.removes.Add(uint64(n))
+	}
+}
+
+// add adds the counters of s to the counters of o.
+func (s *shardStats) add(o *shardStats) {
+	if s == nil || o == nil {
+		return
+	}
+
+	s.hits.Add(o.hits.Load())
+	s.misses.Add(o.misses.Load())
+	s.sets.Add(o.sets.Load())
+	s.removes.Add(o.removes.Load())
+	s.lockWait.Add(o.lockWait.Load())
+}
+
+// addTo adds the counters of s to stats.
+func (s *shardStats) addTo(stats *ShardStats) {
+	if s == nil {
+		return
+	}
+
+	stats.Hits += s.hits.Load()
+	stats.Misses += s.misses.Load()
+	stats.Sets += s.sets.Load()
+	stats.Removes += s.removes.Load()
+	stats.LockWait += time.Duration(s.lockWait.Load())
+}
+
+// Stats returns the statistics of all shards. The counters are read one after another while the Map is in use,
+// so they are not taken at a single point in time. A resize in progress is completed first, so Stats may have to
+// move the remaining shards before it returns.
+func (m *Map) Stats() Stats {
+	t := m.stableTable()
+
+	stats := Stats{Shards: make([]ShardStats, len(t.shards))} //nolint:exhaustivestruct
+
+	for i, shard := range t.shards {
+		s := &stats.Shards[i]
+		s.Entries = int(shard.Count())
+
+		if counter, ok := shard.Shard.(EvictionCounter); ok {
+			s.Evictions = counter.Evictions()
+		}
+
+		shard.stats.addTo(s)
+
+		stats.Total.Entries += s.Entries
+		stats.Total.Hits += s.Hits
+		stats.Total.Misses += s.Misses
+		stats.Total.Sets += s.Sets
+		stats.Total.Removes += s.Removes
+		stats.Total.Evictions += s.Evictions
+		stats.Total.LockWait += s.LockWait
+	}
+
+	stats.Total.Evictions += m.retiredEvictions.Load()
+	m.retiredStats.addTo(&stats.Total)
+
+	return stats
+}
+
+// ExpvarVar returns an expvar.Var that reports the Stats of the Map as JSON. Publish it with expvar.Publish
+// to export the statistics on /debug/vars:
+//
+//	expvar.Publish("sessions", m.ExpvarVar())
+func (m *Map) ExpvarVar() expvar.Var {
+	return expvar.Func(func() interface{} {
+		return m.Stats()
+	})
+}
@@ -0,0 +1,90 @@
+package shardedmap_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	instance := shardedmap.New(shardedmap.WithShardCount(4), shardedmap.WithStats())
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, instance.Set(fmt.Sprint(i), i))
+	}
+
+	for i := 0; i < 150; i++ {
+		instance.MustGet(fmt.Sprint(i))
+	}
+
+	instance.Has("0")
+	instance.Remove("0")
+
+	// Nothing is removed, so nothing is counted
+	instance.Remove("absent")
+	instance.RemoveMany([]string{"absent"})
+	assert.False(t, instance.CompareAndDelete("2", 3))
+	_, _, err := instance.Compute("absent", func(interface{}, bool) (interface{}, bool) { return nil, false })
+	assert.NoError(t, err)
+
+	_, _, err = instance.GetOrSet("1", 1)
+	assert.NoError(t, err)
+	_, _, err = instance.GetOrSet("new", 1)
+	assert.NoError(t, err)
+
+	stats := instance.Stats()
+	assert.Len(t, stats.Shards, 4)
+	assert.Equal(t, shardedmap.ShardStats{
+		Entries:   100,
+		Hits:      102,
+		Misses:    51,
+		Sets:      101,
+		Removes:   1,
+		Evictions: 0,
+		LockWait:  stats.Total.LockWait,
+	}, stats.Total)
+
+	var entries uint64
+
+	for _, shard := range stats.Shards {
+		entries += uint64(shard.Entries)
+	}
+
+	assert.Equal(t, uint64(100), entries)
+
+	// Counters of replaced shards are kept
+	assert.NoError(t, instance.Resize(2))
+	assert.Equal(t, stats.Total, instance.Stats().Total)
+}
+
+func TestStatsDisabled(t *testing.T) {
+	instance := shardedmap.New(
+		shardedmap.WithShardCount(1),
+		shardedmap.WithMaxEntries(2),
+		shardedmap.WithCustomShardProvider(shardedmap.NewLRUShard),
+	)
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, instance.Set(fmt.Sprint(i), i))
+		instance.MustGet(fmt.Sprint(i))
+	}
+
+	stats := instance.Stats()
+	assert.Equal(t, 2, stats.Total.Entries)
+	assert.Equal(t, uint64(1), stats.Total.Evictions)
+	assert.Zero(t, stats.Total.Hits+stats.Total.Sets)
+}
+
+func TestStatsExpvar(t *testing.T) {
+	instance := shardedmap.New(shardedmap.WithShardCount(2), shardedmap.WithStats())
+	assert.NoError(t, instance.Set("a", 1))
+
+	var stats shardedmap.Stats
+	assert.NoError(t, json.Unmarshal([]byte(instance.ExpvarVar().String()), &stats))
+
+	assert.Len(t, stats.Shards, 2)
+	assert.Equal(t, 1, stats.Total.Entries)
+	assert.Equal(t, uint64(1), stats.Total.Sets)
+}
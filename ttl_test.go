@@ -0,0 +1,66 @@
+package shardedmap_test
+
+import (
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const testTTL = 20 * time.Millisecond
+
+func TestSetWithTTL(t *testing.T) {
+	for _, provider := range []shardedmap.ShardProviderFunc{shardedmap.NewMutexShard, shardedmap.NewAtomicShard} {
+		instance := shardedmap.New(shardedmap.WithCustomShardProvider(provider))
+
+		instance.SetWithTTL("short", 1, testTTL)
+		instance.SetWithTTL("forever", 2, 0)
+		instance.Set("default", 3)
+
+		assert.True(t, instance.Has("short"))
+		assert.Equal(t, 1, instance.MustGet("short"))
+
+		time.Sleep(2 * testTTL)
+
+		assert.False(t, instance.Has("short"))
+		_, err := instance.Get("short")
+		assert.Error(t, err)
+
+		assert.Equal(t, map[string]interface{}{"forever": 2, "default": 3}, instance.All())
+	}
+}
+
+func TestDefaultTTL(t *testing.T) {
+	instance := shardedmap.New(shardedmap.WithDefaultTTL(testTTL))
+
+	instance.Set("key", "value")
+	instance.SetWithTTL("forever", "value", 0)
+	assert.True(t, instance.Has("key"))
+
+	assert.Eventually(t, func() bool { return !instance.Has("key") }, time.Second, testTTL)
+	assert.True(t, instance.Has("forever"))
+
+	// Without a janitor expired entries are only hidden
+	assert.Equal(t, 2, instance.Count())
+}
+
+func TestJanitor(t *testing.T) {
+	instance := shardedmap.New(
+		shardedmap.WithShardCount(4),
+		shardedmap.WithDefaultTTL(testTTL),
+		shardedmap.WithJanitor(testTTL),
+	)
+	defer instance.Close()
+
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		instance.Set(k, k)
+	}
+
+	instance.SetWithTTL("forever", "value", 0)
+
+	assert.Eventually(t, func() bool { return instance.Count() == 1 }, time.Second, testTTL)
+	assert.True(t, instance.Has("forever"))
+
+	instance.Close()
+	instance.Close()
+}
@@ -1,15 +1,40 @@
 package shardedmap
 
+import (
+	"time"
+)
+
 func NewTuple(key string, value interface{}) Tuple {
 	return Tuple{
 		key,
 		value,
+		0,
+		0,
+	}
+}
+
+// NewTupleWithExpiry creates a Tuple that expires at the given unix timestamp in nanoseconds.
+// An expiry of 0 creates a Tuple that never expires.
+func NewTupleWithExpiry(key string, value interface{}, expiry int64) Tuple {
+	return Tuple{
+		key,
+		value,
+		expiry,
+		0,
 	}
 }
 
 type Tuple struct {
-	key   string
-	value interface{}
+	key     string
+	value   interface{}
+	expiry  int64
+	version uint64
+}
+
+// VersionedTuple is implemented by tuples that carry a version, see Tuple.GetVersion.
+type VersionedTuple interface {
+	ShardTuple
+	GetVersion() uint64
 }
 
 func (t Tuple) GetKey() string {
@@ -19,3 +44,36 @@ func (t Tuple) GetKey() string {
 func (t Tuple) GetValue() interface{} {
 	return t.value
 }
+
+// GetExpiry returns the unix timestamp in nanoseconds at which the Tuple expires or 0 if it never expires.
+func (t Tuple) GetExpiry() int64 {
+	return t.expiry
+}
+
+// GetVersion returns the version of the Tuple. A Map assigns the version while it stores the Tuple, so the
+// version of a key increases with every write. Versions start at 1 and are not persisted. Tuples that were not
+// stored by a Map have version 0.
+func (t Tuple) GetVersion() uint64 {
+	return t.version
+}
+
+// versionOf returns the version of tuple or 0 if tuple is nil or has no version.
+func versionOf(tuple ShardTuple) uint64 {
+	if v, ok := tuple.(VersionedTuple); ok {
+		return v.GetVersion()
+	}
+
+	return 0
+}
+
+// IsExpired reports whether tuple is expired at now, given as unix timestamp in nanoseconds.
+func IsExpired(tuple ShardTuple, now int64) bool {
+	expiry := tuple.GetExpiry()
+
+	return expiry != 0 && expiry <= now
+}
+
+// isExpiredNow checks the expiry against the current time. The clock is only read for tuples with an expiry.
+func isExpiredNow(tuple ShardTuple) bool {
+	return tuple.GetExpiry() != 0 && IsExpired(tuple, time.Now().UnixNano())
+}
@@ -0,0 +1,326 @@
+package shardedmap
+
+import (
+	"sort"
+)
+
+// Tx is a transaction on a Map, see Map.Update. It must only be used by the function passed to Update.
+type Tx struct {
+	m     *Map
+	table *shardTable
+	// locked holds the indexes of the shards in table whose gates are held exclusively, in ascending order.
+	locked []uint
+	// writes holds the buffered changes by key in the order of keys.
+	writes map[string]txWrite
+	keys   []string
+	// retry is set if a shard could not be locked in order. pending is the index of this shard.
+	retry   bool
+	pending uint
+	done    bool
+	// stored holds the tuples stored by commit. They are published once the locks are released.
+	stored []ShardTuple
+	// undone is set if commit failed and undid its changes.
+	undone bool
+}
+
+// txWrite is a buffered change. A nil tuple removes the key.
+type txWrite struct {
+	keyHash uint
+	shard   *shardSlot
+	tuple   ShardTuple
+}
+
+// Update runs fn in a transaction. The changes made through tx are applied together once fn returns nil.
+// If fn returns an error, the changes are discarded and Update returns the error. If a change cannot be applied,
+// for example because of ErrCapacity, the changes applied before are undone and Update returns the error.
+//
+// The shards of all keys accessed through tx stay locked until Update returns, so no other writer can modify
+// these keys in the meantime. To avoid deadlocks, a shard is only waited for if its index is higher than the
+// indexes of all locked shards. If another shard is locked by someone else, all locks are released and fn runs
+// again with the shards locked upfront in index order. Until then, the operations of tx fail. So fn may run
+// more than once and must not have side effects beyond tx.
+//
+// Readers that do not lock shards, like Get, may observe a part of the changes while they are applied.
+// fn must not access the Map other than through tx.
+func (m *Map) Update(fn func(tx *Tx) error) error {
+	var (
+		previous *shardTable
+		wanted   []uint
+	)
+
+	for {
+		t := m.stableTable()
+		if t != previous {
+			wanted = nil
+		}
+
+		tx := &Tx{m: m, table: t, writes: make(map[string]txWrite)} //nolint:exhaustivestruct
+
+		err := tx.lockAll(wanted)
+		if err == nil {
+			err = fn(tx)
+		}
+
+		if tx.retry {
+			previous, wanted = t, tx.wanted()
+			tx.release()
+
+			continue
+		}
+
+		if err == nil {
+			err = tx.commit()
+		}
+
+		tx.release()
+
+		return err
+	}
+}
+
+// lockAll locks the shards at indexes in ascending order.
+func (tx *Tx) lockAll(indexes []uint) error {
+	for _, index := range indexes {
+		if err := tx.lockIndex(index); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// wanted returns the indexes of the locked shards and the shard that could not be locked in ascending order.
+func (tx *Tx) wanted() []uint {
+	indexes := append([]uint{tx.pending}, tx.locked...)
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	return indexes
+}
+
+// lockIndex locks the shard at index, see Map.Update.
+func (tx *Tx) lockIndex(index uint) error {
+	i := sort.Search(len(tx.locked), func(i int) bool { return tx.locked[i] >= index })
+	if i < len(tx.locked) && tx.locked[i] == index {
+		return nil
+	}
+
+	shard := tx.table.shards[index]
+
+	if i == len(tx.locked) {
+		shard.lockGate(true)
+	} else if !shard.gate.TryLock() {
+		tx.retry, tx.pending = true, index
+
+		return errTxRetry
+	}
+
+	shard.deferring = true
+
+	tx.locked = append(tx.locked, 0)
+	copy(tx.locked[i+1:], tx.locked[i:])
+	tx.locked[i] = index
+
+	// A Resize started after the table was loaded. The next run uses the new table.
+	if shard.evacuated.Load() {
+		tx.retry, tx.pending = true, index
+
+		return errTxRetry
+	}
+
+	return nil
+}
+
+// lock locks the shard of key and returns the hash of key and the shard.
+func (tx *Tx) lock(key string) (uint, *shardSlot, error) {
+	switch {
+	case tx.done:
+		return 0, nil, ErrTxDone
+	case tx.retry:
+		return 0, nil, errTxRetry
+	}
+
+	keyHash := tx.m.getKeyHash(key)
+	index := tx.table.index(keyHash)
+
+	if err := tx.lockIndex(index); err != nil {
+		return 0, nil, err
+	}
+
+	return keyHash, tx.table.shards[index], nil
+}
+
+// release releases all locks and ends the transaction.
+//
+// The evictions caused by commit are buffered while the shards are locked and delivered afterwards, together with
+// the events for the stored tuples, so eviction callbacks and subscribers of Watch may access the Map. If commit
+// failed, the removals it undid are not delivered.
+func (tx *Tx) release() {
+	var evictions []evictionEvent
+
+	for _, index := range tx.locked {
+		shard := tx.table.shards[index]
+		evictions = append(evictions, shard.deferred...)
+		shard.deferring, shard.deferred = false, nil
+		shard.gate.Unlock()
+	}
+
+	for _, index := range tx.locked {
+		tx.m.growIfNeeded(tx.table.shards[index])
+	}
+
+	tx.locked = nil
+	tx.done = true
+
+	if len(evictions) > 0 {
+		handler := tx.m.evictionHandler()
+
+		for _, e := range evictions {
+			if _, written := tx.writes[e.tuple.GetKey()]; tx.undone && written && e.reason == EvictionReasonRemoved {
+				continue
+			}
+
+			handler(e.tuple, e.reason)
+		}
+	}
+
+	for _, tuple := range tx.stored {
+		tx.m.notifySet(tuple)
+	}
+}
+
+// Get returns the value for key including the changes made by tx or a KeyError wrapping ErrNotFound or
+// ErrExpired.
+func (tx *Tx) Get(key string) (interface{}, error) {
+	keyHash, shard, err := tx.lock(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if w, ok := tx.writes[key]; ok {
+		if w.tuple == nil {
+			return nil, newKeyError(key, ErrNotFound)
+		}
+
+		return w.tuple.GetValue(), nil
+	}
+
+	value, err := shard.Get(keyHash, key)
+	shard.stats.lookup(err == nil)
+
+	return value, err
+}
+
+// Has checks the existence of key including the changes made by tx.
+func (tx *Tx) Has(key string) bool {
+	_, err := tx.Get(key)
+
+	return err == nil
+}
+
+// Set sets the value for key when the transaction is committed. The entry expires after the default TTL if one
+// is configured.
+func (tx *Tx) Set(key string, value interface{}) error {
+	return tx.write(key, tx.m.newTuple(key, value))
+}
+
+// Remove removes key when the transaction is committed.
+func (tx *Tx) Remove(key string) error {
+	return tx.write(key, nil)
+}
+
+func (tx *Tx) write(key string, tuple ShardTuple) error {
+	keyHash, shard, err := tx.lock(key)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := tx.writes[key]; !ok {
+		tx.keys = append(tx.keys, key)
+	}
+
+	tx.writes[key] = txWrite{keyHash: keyHash, shard: shard, tuple: tuple}
+
+	return nil
+}
+
+// commit applies the buffered changes. If a change fails, the changes applied before are undone. The log records
+// are encoded first, so a transaction is not applied if it cannot be logged.
+func (tx *Tx) commit() error {
+	records := make([][]byte, len(tx.keys))
+
+	for i, key := range tx.keys {
+		var err error
+
+		if w := tx.writes[key]; w.tuple == nil {
+			records[i], err = tx.m.encodeRemove(key)
+		} else {
+			records[i], err = tx.m.encodeSet(w.tuple)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	previous := make([]ShardTuple, len(tx.keys))
+	stamped := make([]ShardTuple, len(tx.keys))
+
+	for i, key := range tx.keys {
+		w := tx.writes[key]
+
+		var err error
+
+		stamped[i], err = w.shard.Compute(w.keyHash, key, func(current ShardTuple) (ShardTuple, bool) {
+			previous[i] = current
+
+			return w.shard.stamp(w.tuple), true
+		})
+		if err != nil {
+			tx.undo(previous[:i])
+
+			return err
+		}
+	}
+
+	var firstErr error
+
+	for i, key := range tx.keys {
+		w := tx.writes[key]
+
+		var err error
+
+		if w.tuple == nil {
+			if previous[i] != nil {
+				w.shard.stats.remove()
+			}
+
+			err = tx.m.appendLog(records[i])
+		} else {
+			w.shard.stats.set()
+
+			if err = tx.m.appendLog(records[i]); err == nil {
+				tx.stored = append(tx.stored, stamped[i])
+			}
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// undo restores the tuples replaced by the first changes in reverse order.
+func (tx *Tx) undo(previous []ShardTuple) {
+	tx.undone = true
+
+	for i := len(previous) - 1; i >= 0; i-- {
+		w := tx.writes[tx.keys[i]]
+
+		// Undoing the changes in reverse order frees the capacity for the restored tuples
+		_, _ = w.shard.Compute(w.keyHash, tx.keys[i], func(ShardTuple) (ShardTuple, bool) {
+			return previous[i], true
+		})
+	}
+}
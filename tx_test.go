@@ -0,0 +1,183 @@
+package shardedmap_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func transfer(instance *shardedmap.Map, from, to string, amount int) error {
+	return instance.Update(func(tx *shardedmap.Tx) error {
+		balance, err := tx.Get(from)
+		if err != nil {
+			return err
+		}
+
+		target, err := tx.Get(to)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Set(from, balance.(int)-amount); err != nil { //nolint:forcetypeassert
+			return err
+		}
+
+		return tx.Set(to, target.(int)+amount) //nolint:forcetypeassert
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	instance := shardedmap.New()
+	assert.NoError(t, instance.Set("a", 100))
+	assert.NoError(t, instance.Set("b", 0))
+
+	assert.NoError(t, transfer(instance, "a", "b", 30))
+	assert.Equal(t, 70, instance.MustGet("a"))
+	assert.Equal(t, 30, instance.MustGet("b"))
+
+	errAbort := errors.New("abort")
+
+	err := instance.Update(func(tx *shardedmap.Tx) error {
+		assert.NoError(t, tx.Set("a", 0))
+		assert.NoError(t, tx.Remove("b"))
+		assert.NoError(t, tx.Set("c", 1))
+
+		// Changes are visible to the transaction only
+		assert.Equal(t, 0, must(tx.Get("a")))
+		assert.False(t, tx.Has("b"))
+		assert.Equal(t, 70, instance.MustGet("a"))
+
+		return errAbort
+	})
+
+	assert.ErrorIs(t, err, errAbort)
+	assert.Equal(t, map[string]interface{}{"a": 70, "b": 30}, instance.All())
+
+	assert.NoError(t, instance.Update(func(tx *shardedmap.Tx) error {
+		assert.NoError(t, tx.Remove("b"))
+
+		return tx.Set("c", 1)
+	}))
+	assert.Equal(t, map[string]interface{}{"a": 70, "c": 1}, instance.All())
+}
+
+func must(value interface{}, err error) interface{} {
+	if err != nil {
+		return err
+	}
+
+	return value
+}
+
+func TestUpdateUndoesChangesOnError(t *testing.T) {
+	recorder := newEvictionRecorder()
+	instance := shardedmap.New(
+		shardedmap.WithShardCount(1),
+		shardedmap.WithMaxEntries(2),
+		shardedmap.WithOnEvict(recorder.onEvict),
+	)
+	assert.NoError(t, instance.Set("a", 1))
+	assert.NoError(t, instance.Set("b", 2))
+
+	err := instance.Update(func(tx *shardedmap.Tx) error {
+		assert.NoError(t, tx.Set("a", 10))
+		assert.NoError(t, tx.Remove("b"))
+		assert.NoError(t, tx.Set("c", 3))
+
+		return tx.Set("d", 4)
+	})
+
+	assert.ErrorIs(t, err, shardedmap.ErrCapacity)
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 2}, instance.All())
+
+	// Nothing was removed in the end
+	assert.Empty(t, recorder.get())
+}
+
+func TestUpdateEvictionCallbackMayWrite(t *testing.T) {
+	var instance *shardedmap.Map
+
+	instance = shardedmap.New(
+		shardedmap.WithShardCount(1),
+		shardedmap.WithOnEvict(func(key string, value interface{}, reason shardedmap.EvictionReason) {
+			assert.NoError(t, instance.Set(key+"-removed", value))
+		}),
+	)
+	assert.NoError(t, instance.Set("a", 1))
+
+	done := make(chan error)
+
+	go func() {
+		done <- instance.Update(func(tx *shardedmap.Tx) error {
+			return tx.Remove("a")
+		})
+	}()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("eviction callback is blocked")
+	}
+
+	assert.Equal(t, map[string]interface{}{"a-removed": 1}, instance.All())
+}
+
+func TestUpdateAfterDone(t *testing.T) {
+	instance := shardedmap.New()
+
+	var leaked *shardedmap.Tx
+
+	assert.NoError(t, instance.Update(func(tx *shardedmap.Tx) error {
+		leaked = tx
+
+		return nil
+	}))
+
+	assert.ErrorIs(t, leaked.Set("a", 1), shardedmap.ErrTxDone)
+	assert.False(t, instance.Has("a"))
+}
+
+func TestUpdateWithConcurrentTransactions(t *testing.T) {
+	const accounts, transfers = 10, 200
+
+	instance := shardedmap.New(shardedmap.WithShardCount(4))
+
+	for i := 0; i < accounts; i++ {
+		assert.NoError(t, instance.Set(fmt.Sprint(i), 1000))
+	}
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < transfers; i++ {
+				// Transfers in both directions lock the shards in different orders
+				from, to := (w+i)%accounts, (w+i*7+1)%accounts
+				if from != to {
+					assert.NoError(t, transfer(instance, fmt.Sprint(from), fmt.Sprint(to), 1))
+				}
+			}
+		}(w)
+	}
+
+	assert.NoError(t, instance.Resize(8))
+
+	wg.Wait()
+
+	total := 0
+
+	for _, balance := range instance.All() {
+		total += balance.(int) //nolint:forcetypeassert
+	}
+
+	assert.Equal(t, accounts*1000, total)
+}
@@ -0,0 +1,132 @@
+package shardedmap
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// shardWaiters holds the goroutines waiting for keys of a shard to be set, see Map.WaitFor.
+type shardWaiters struct {
+	mu    sync.Mutex
+	byKey map[string][]chan struct{}
+}
+
+// add registers a waiter for key and returns the channel that is closed once key is set. It returns nil if the
+// shard was evacuated, the waiter must then register with the shard the key was moved to.
+func (w *shardWaiters) add(key string, evacuated *atomic.Bool) chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if evacuated.Load() {
+		return nil
+	}
+
+	if w.byKey == nil {
+		w.byKey = make(map[string][]chan struct{})
+	}
+
+	wake := make(chan struct{})
+	w.byKey[key] = append(w.byKey[key], wake)
+
+	return wake
+}
+
+// remove unregisters a waiter that stops waiting.
+func (w *shardWaiters) remove(key string, wake chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	waiters := w.byKey[key]
+
+	for i, c := range waiters {
+		if c == wake {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+
+			break
+		}
+	}
+
+	if len(waiters) == 0 {
+		delete(w.byKey, key)
+	} else {
+		w.byKey[key] = waiters
+	}
+}
+
+// wake wakes all waiters for key.
+func (w *shardWaiters) wake(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, wake := range w.byKey[key] {
+		close(wake)
+	}
+
+	delete(w.byKey, key)
+}
+
+// wakeAll wakes all waiters of the shard.
+func (w *shardWaiters) wakeAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, waiters := range w.byKey {
+		for _, wake := range waiters {
+			close(wake)
+		}
+	}
+
+	w.byKey = nil
+}
+
+// WaitFor returns the value for key. If key is absent or expired, WaitFor blocks until key is set or ctx is done
+// and then returns ctx.Err().
+//
+// Waiters are registered with the shard of the key and woken by the writer that sets the key. As long as no
+// goroutine waits, writers only check an atomic counter.
+func (m *Map) WaitFor(ctx context.Context, key string) (interface{}, error) {
+	if value, err := m.Get(key); err == nil {
+		return value, nil
+	}
+
+	keyHash := m.getKeyHash(key)
+
+	// Writers check the counter after storing the key, so either the writer sees the waiter or the waiter sees
+	// the key below
+	m.waiting.Add(1)
+	defer m.waiting.Add(-1)
+
+	for {
+		shard := m.readShard(keyHash)
+
+		wake := shard.waiters.add(key, &shard.evacuated)
+		if wake == nil {
+			continue
+		}
+
+		if value, err := m.Get(key); err == nil {
+			shard.waiters.remove(key, wake)
+
+			return value, nil
+		}
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			shard.waiters.remove(key, wake)
+
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// wakeWaiters wakes the goroutines waiting for key in WaitFor. If the shard of key was evacuated meanwhile, its
+// waiters were already woken by the evacuation and wait for the new shard.
+func (m *Map) wakeWaiters(key string) {
+	if m.waiting.Load() == 0 {
+		return
+	}
+
+	m.readShard(m.getKeyHash(key)).waiters.wake(key)
+}
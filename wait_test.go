@@ -0,0 +1,96 @@
+package shardedmap_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWaitFor(t *testing.T) {
+	instance := shardedmap.New()
+	assert.NoError(t, instance.Set("present", 1))
+
+	value, err := instance.WaitFor(context.Background(), "present")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	result := make(chan interface{})
+
+	go func() {
+		value, err := instance.WaitFor(context.Background(), "key")
+		assert.NoError(t, err)
+
+		result <- value
+	}()
+
+	// Setting other keys does not wake the waiter
+	assert.NoError(t, instance.Set("other", 1))
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, instance.Set("key", 2))
+
+	select {
+	case value := <-result:
+		assert.Equal(t, 2, value)
+	case <-time.After(time.Second):
+		t.Fatal("waiter not woken")
+	}
+}
+
+func TestWaitForContextDone(t *testing.T) {
+	instance := shardedmap.New()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := instance.WaitFor(ctx, "key")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Expired keys are absent
+	assert.NoError(t, instance.SetWithTTL("expired", 1, time.Nanosecond))
+	_, err = instance.WaitFor(ctx, "expired")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWaitForWithConcurrentWritersAndResize(t *testing.T) {
+	const keys = 200
+
+	instance := shardedmap.New(shardedmap.WithShardCount(1))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < keys; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			value, err := instance.WaitFor(ctx, fmt.Sprint(i))
+			assert.NoError(t, err)
+			assert.Equal(t, i, value)
+		}(i)
+	}
+
+	go func() {
+		for _, count := range []int{4, 16, 3} {
+			assert.NoError(t, instance.Resize(count))
+		}
+	}()
+
+	for i := 0; i < keys; i++ {
+		if i%2 == 0 {
+			assert.NoError(t, instance.Set(fmt.Sprint(i), i))
+		} else {
+			_, _, err := instance.GetOrSet(fmt.Sprint(i), i)
+			assert.NoError(t, err)
+		}
+	}
+
+	wg.Wait()
+}
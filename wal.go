@@ -0,0 +1,682 @@
+package shardedmap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WALSyncPolicy defines when the write-ahead log is flushed to stable storage with fsync.
+type WALSyncPolicy int
+
+const (
+	// WALSyncAlways syncs the log before every write returns. No acknowledged write is lost on power failure.
+	WALSyncAlways WALSyncPolicy = iota
+	// WALSyncInterval syncs the log in the background at the interval given to WithWALSync. Writes of the last
+	// interval may be lost on power failure, but not if only the process crashes.
+	WALSyncInterval
+	// WALSyncNever leaves syncing to the operating system.
+	WALSyncNever
+)
+
+// The files of a write-ahead log directory. The log has the header of a snapshot with another magic, followed
+// by records:
+//
+//	record:  uvarint len(payload) | payload | uint32 CRC-32C of payload
+//	payload: walOpSet | uvarint len(key) | key | varint expiry | uvarint len(value) | value
+//	         walOpRemove | uvarint len(key) | key
+//	         walOpClear
+//
+// Compaction renames the log to walOldFile, starts a new log and writes a snapshot of the Map. Changes that are
+// applied while the snapshot is written are part of the new log and may also be part of the snapshot. Replaying
+// them again is harmless, because every record stores the resulting state of a key instead of the operation.
+const (
+	walMagic        = "SHRDWAL\x00"
+	walSnapshotFile = "snapshot"
+	walFile         = "wal"
+	walOldFile      = "wal.old"
+
+	defaultWALSyncInterval = time.Second
+
+	walOpSet    byte = 1
+	walOpRemove byte = 2
+	walOpClear  byte = 3
+)
+
+// wal is the write-ahead log of a Map.
+type wal struct {
+	dir    string
+	codec  ValueCodec
+	policy WALSyncPolicy
+
+	// mu guards the log file and all fields below.
+	mu    sync.Mutex
+	file  *os.File
+	size  int64
+	dirty bool
+
+	// err is the first error that occurred while appending. Later appends return it.
+	err error
+
+	// replaying is set while the log is replayed by Open, so replayed changes are not appended again.
+	replaying bool
+
+	compactMu  sync.Mutex
+	compacting atomic.Bool
+	stop       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// Open creates a Map that persists all changes of Set, Remove, Clear and the other modifying operations to a
+// write-ahead log in dir. The directory is created if needed. If it already holds a log, the Map is restored
+// from it. Values are encoded with the ValueCodec set by WithValueCodec.
+//
+// A change is only applied if it can be logged. If the Map is closed, an append failed before or the value cannot
+// be encoded, writes return the error and leave the Map unchanged. Only if the append itself fails, the change
+// is applied without being logged.
+//
+// Entries removed by expiry or capacity evictions are not logged, they are evicted again after a restart.
+// While the log is enabled, writers of the same shard do not run concurrently, so they are logged in the
+// order they are applied. Eviction callbacks must not modify the Map.
+//
+// Call Map.Close to flush and close the log.
+func Open(dir string, opts ...MapOption) (*Map, error) {
+	m := New(opts...)
+	m.wal = &wal{ //nolint:exhaustivestruct
+		dir:       dir,
+		codec:     m.valueCodec,
+		policy:    m.walSyncPolicy,
+		replaying: true,
+		stop:      make(chan struct{}),
+	}
+
+	if err := m.wal.open(m); err != nil {
+		_ = m.Close()
+
+		return nil, err
+	}
+
+	if m.walSyncPolicy == WALSyncInterval {
+		interval := m.walSyncInterval
+		if interval <= 0 {
+			interval = defaultWALSyncInterval
+		}
+
+		m.wal.startSyncer(interval)
+	}
+
+	return m, nil
+}
+
+func (w *wal) path(name string) string {
+	return filepath.Join(w.dir, name)
+}
+
+// open restores m from the files in the log directory and opens the log for appending.
+func (w *wal) open(m *Map) error {
+	if err := os.MkdirAll(w.dir, 0o755); err != nil { //nolint:gomnd
+		return err
+	}
+
+	if err := w.readSnapshot(m); err != nil {
+		return err
+	}
+
+	// A log is left over if a compaction did not finish
+	if _, err := w.replay(m, walOldFile); err != nil {
+		return err
+	}
+
+	size, err := w.replay(m, walFile)
+	if err != nil {
+		return err
+	}
+
+	w.replaying = false
+
+	if _, err := os.Stat(w.path(walOldFile)); err == nil {
+		// All changes are in memory now. A new snapshot replaces both logs.
+		if err := w.writeSnapshot(m); err != nil {
+			return err
+		}
+
+		return w.create()
+	}
+
+	if size == 0 {
+		return w.create()
+	}
+
+	// Drop a record that was not completely written
+	w.file, err = os.OpenFile(w.path(walFile), os.O_WRONLY, 0o644) //nolint:gomnd
+	if err != nil {
+		return err
+	}
+
+	if err := w.file.Truncate(size); err != nil {
+		return err
+	}
+
+	w.size = size
+	_, err = w.file.Seek(size, io.SeekStart)
+
+	return err
+}
+
+func (w *wal) readSnapshot(m *Map) error {
+	f, err := os.Open(w.path(walSnapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return m.ReadSnapshot(f)
+}
+
+// writeSnapshot replaces the snapshot file atomically with a snapshot of m and removes walOldFile.
+func (w *wal) writeSnapshot(m *Map) error {
+	tmp := w.path(walSnapshotFile + ".tmp")
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	err = m.WriteSnapshot(f)
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, w.path(walSnapshotFile)); err != nil {
+		return err
+	}
+
+	if err := w.syncDir(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(w.path(walOldFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (w *wal) syncDir() error {
+	dir, err := os.Open(w.dir)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
+
+// create starts a new, empty log. The caller must hold mu or be the only user of w.
+func (w *wal) create() error {
+	f, err := os.Create(w.path(walFile))
+	if err != nil {
+		return err
+	}
+
+	header := w.header()
+
+	if _, err := f.Write(header); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	w.file, w.size = f, int64(len(header))
+
+	return w.syncDir()
+}
+
+// replay applies the records of the log file name to m. It returns the size of the valid part of the file.
+// Replaying stops at the first incomplete or corrupted record, which is what a crash during an append leaves.
+func (w *wal) replay(m *Map, name string) (int64, error) {
+	f, err := os.Open(w.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	if err := newSnapshotReader(r).readHeader(walMagic, w.codec.Name()); err != nil {
+		// The log was created, but its header was not written completely
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	size := w.headerSize()
+
+	for {
+		payload, n, err := readWALRecord(r)
+		if err != nil {
+			return size, nil //nolint:nilerr
+		}
+
+		if err := w.apply(m, payload); err != nil {
+			return 0, err
+		}
+
+		size += n
+	}
+}
+
+// header returns the header of a log file.
+func (w *wal) header() []byte {
+	var header bytes.Buffer
+
+	sw := newSnapshotWriter(&header)
+	sw.writeHeader(walMagic, w.codec.Name())
+	_ = sw.flush()
+
+	return header.Bytes()
+}
+
+func (w *wal) headerSize() int64 {
+	return int64(len(w.header()))
+}
+
+// readWALRecord reads the next record and returns its payload and its size in the log.
+func readWALRecord(r *bufio.Reader) ([]byte, int64, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if length > maxSnapshotFieldSize {
+		return nil, 0, errFieldTooLarge
+	}
+
+	payload := make([]byte, length+4) //nolint:gomnd
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, err
+	}
+
+	payload, checksum := payload[:length], binary.LittleEndian.Uint32(payload[length:])
+	if crc32.Checksum(payload, castagnoliTable) != checksum {
+		return nil, 0, errChecksumMismatch
+	}
+
+	var buf [binary.MaxVarintLen64]byte
+
+	return payload, int64(binary.PutUvarint(buf[:], length)) + int64(length) + 4, nil //nolint:gomnd
+}
+
+// apply applies the change of a record to m.
+func (w *wal) apply(m *Map, payload []byte) error {
+	r := bytes.NewReader(payload)
+
+	op, err := r.ReadByte()
+	if err != nil {
+		return invalidSnapshot(err)
+	}
+
+	if op == walOpClear {
+		m.Clear()
+
+		return nil
+	}
+
+	key, err := readWALBytes(r)
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case walOpRemove:
+		m.Remove(string(key))
+
+		return nil
+	case walOpSet:
+		expiry, err := binary.ReadVarint(r)
+		if err != nil {
+			return invalidSnapshot(err)
+		}
+
+		data, err := readWALBytes(r)
+		if err != nil {
+			return err
+		}
+
+		value, err := w.codec.Decode(data)
+		if err != nil {
+			return newKeyError(string(key), err)
+		}
+
+		// Entries that do not fit anymore are dropped like capacity evictions
+		if err := m.storeTuple(NewTupleWithExpiry(string(key), value, expiry)); err != nil &&
+			!errors.Is(err, ErrCapacity) {
+			return err
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown log operation %d", ErrInvalidSnapshot, op)
+	}
+}
+
+func readWALBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, invalidSnapshot(err)
+	}
+
+	if n > uint64(r.Len()) {
+		return nil, invalidSnapshot(io.ErrUnexpectedEOF)
+	}
+
+	p := make([]byte, n)
+	_, _ = r.Read(p)
+
+	return p, nil
+}
+
+// record returns the record of a change for write. tuple is only used by walOpSet, key by walOpRemove. It fails
+// if the log is closed or broken or the value cannot be encoded, so writers check it before changing the Map.
+// While the log is replayed, record returns nil.
+func (w *wal) record(op byte, key string, tuple ShardTuple) ([]byte, error) {
+	if w.replaying {
+		return nil, nil //nolint:nilnil
+	}
+
+	if err := w.usable(); err != nil {
+		return nil, err
+	}
+
+	payload := []byte{op}
+
+	if op != walOpClear {
+		payload = binary.AppendUvarint(payload, uint64(len(key)))
+		payload = append(payload, key...)
+	}
+
+	if op == walOpSet {
+		value, err := w.codec.Encode(tuple.GetValue())
+		if err != nil {
+			return nil, newKeyError(key, err)
+		}
+
+		payload = binary.AppendVarint(payload, tuple.GetExpiry())
+		payload = binary.AppendUvarint(payload, uint64(len(value)))
+		payload = append(payload, value...)
+	}
+
+	record := binary.AppendUvarint(make([]byte, 0, len(payload)+binary.MaxVarintLen64+4), uint64(len(payload)))
+	record = append(record, payload...)
+	record = binary.LittleEndian.AppendUint32(record, crc32.Checksum(payload, castagnoliTable))
+
+	return record, nil
+}
+
+// usable returns the error that makes appending to the log fail.
+func (w *wal) usable() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+
+	if w.file == nil {
+		return ErrClosed
+	}
+
+	return nil
+}
+
+// write appends a record returned by record. Writers check the log with record before they change the Map, so
+// write only fails if the log fails meanwhile. The change is then applied, but not logged.
+func (w *wal) write(record []byte) error {
+	if record == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+
+	if w.file == nil {
+		return ErrClosed
+	}
+
+	if _, err := w.file.Write(record); err != nil {
+		w.err = err
+
+		return err
+	}
+
+	w.size += int64(len(record))
+	w.dirty = true
+
+	if w.policy == WALSyncAlways {
+		return w.syncLocked()
+	}
+
+	return nil
+}
+
+// syncLocked syncs the log if it was written since the last sync. The caller must hold mu.
+func (w *wal) syncLocked() error {
+	if !w.dirty || w.file == nil {
+		return nil
+	}
+
+	if err := w.file.Sync(); err != nil {
+		w.err = err
+
+		return err
+	}
+
+	w.dirty = false
+
+	return nil
+}
+
+func (w *wal) startSyncer(interval time.Duration) {
+	w.wg.Add(1)
+
+	go func() {
+		defer w.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				w.mu.Lock()
+				_ = w.syncLocked()
+				w.mu.Unlock()
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// close stops the background goroutines, syncs and closes the log.
+func (w *wal) close() error {
+	w.mu.Lock()
+	close(w.stop)
+	w.mu.Unlock()
+
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return w.err
+	}
+
+	err := w.syncLocked()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	w.file = nil
+
+	return err
+}
+
+// Compact replaces the write-ahead log with a snapshot of the Map. Writers are only blocked while the log file
+// is switched. Compact does nothing for Maps that are not created by Open.
+func (m *Map) Compact() error {
+	w := m.wal
+	if w == nil {
+		return nil
+	}
+
+	w.compactMu.Lock()
+	defer w.compactMu.Unlock()
+
+	if err := w.rotate(); err != nil {
+		return err
+	}
+
+	return w.writeSnapshot(m)
+}
+
+// rotate renames the log to walOldFile and starts a new log. If walOldFile is left over by a failed compaction,
+// the log is kept. The next snapshot covers both logs anyway.
+func (w *wal) rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+
+	if w.file == nil {
+		return ErrClosed
+	}
+
+	if _, err := os.Stat(w.path(walOldFile)); err == nil {
+		return nil
+	}
+
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	w.file, w.dirty = nil, false
+
+	if err := os.Rename(w.path(walFile), w.path(walOldFile)); err != nil {
+		w.err = err
+
+		return err
+	}
+
+	if err := w.create(); err != nil {
+		w.err = err
+
+		return err
+	}
+
+	return nil
+}
+
+// compactIfNeeded starts a compaction in the background if the log is larger than the limit of
+// WithWALCompaction. No compaction is started once the log is closing.
+func (m *Map) compactIfNeeded() {
+	w := m.wal
+
+	// The goroutine is added under mu, so close either waits for it or it is not started
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if m.walCompactSize <= 0 || w.size < m.walCompactSize || w.closing() || !w.compacting.CompareAndSwap(false, true) {
+		return
+	}
+
+	w.wg.Add(1)
+
+	go func() {
+		defer w.wg.Done()
+		defer w.compacting.Store(false)
+
+		_ = m.Compact()
+	}()
+}
+
+// closing reports whether close was called. The caller must hold mu.
+func (w *wal) closing() bool {
+	select {
+	case <-w.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// encodeSet returns the log record for storing tuple, see wal.record. It returns nil if the Map has no
+// write-ahead log.
+func (m *Map) encodeSet(tuple ShardTuple) ([]byte, error) {
+	if m.wal == nil {
+		return nil, nil //nolint:nilnil
+	}
+
+	return m.wal.record(walOpSet, tuple.GetKey(), tuple)
+}
+
+// encodeRemove returns the log record for removing key, see encodeSet.
+func (m *Map) encodeRemove(key string) ([]byte, error) {
+	if m.wal == nil {
+		return nil, nil //nolint:nilnil
+	}
+
+	return m.wal.record(walOpRemove, key, nil)
+}
+
+// appendLog appends a record returned by encodeSet or encodeRemove to the write-ahead log. The caller must hold
+// the gate of the shard, so the changes of a shard are logged in the order they are applied.
+func (m *Map) appendLog(record []byte) error {
+	if record == nil {
+		return nil
+	}
+
+	defer m.compactIfNeeded()
+
+	return m.wal.write(record)
+}
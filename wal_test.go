@@ -0,0 +1,227 @@
+package shardedmap_test
+
+import (
+	"fmt"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOpenRestoresChanges(t *testing.T) {
+	dir := t.TempDir()
+
+	instance, err := shardedmap.Open(dir, shardedmap.WithShardCount(4))
+	require.NoError(t, err)
+
+	assert.NoError(t, instance.Set("a", 1))
+	assert.NoError(t, instance.Set("b", persistedValue{Name: "b", Count: 2}))
+	assert.NoError(t, instance.SetWithTTL("ttl", "value", time.Hour))
+	assert.NoError(t, instance.Set("removed", 3))
+	instance.Remove("removed")
+
+	_, err = instance.Upsert("a", func(old interface{}, exists bool) interface{} {
+		return old.(int) + 10 //nolint:forcetypeassert
+	})
+	assert.NoError(t, err)
+	assert.True(t, instance.CompareAndSwap("b", persistedValue{Name: "b", Count: 2}, "swapped"))
+	assert.NoError(t, instance.Update(func(tx *shardedmap.Tx) error {
+		assert.NoError(t, tx.Set("tx", 4))
+
+		return tx.Remove("ttl")
+	}))
+	assert.NoError(t, instance.SetWithTTL("ttl", "value", time.Hour))
+
+	assert.NoError(t, instance.Close())
+	assert.ErrorIs(t, instance.Set("closed", 1), shardedmap.ErrClosed)
+
+	restored, err := shardedmap.Open(dir, shardedmap.WithShardCount(2))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"a": 11, "b": "swapped", "ttl": "value", "tx": 4}, restored.All())
+
+	restored.Clear()
+	assert.NoError(t, restored.Set("after-clear", true))
+	assert.NoError(t, restored.Close())
+
+	restored, err = shardedmap.Open(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"after-clear": true}, restored.All())
+	assert.NoError(t, restored.Close())
+}
+
+func TestWritesThatCannotBeLoggedAreNotApplied(t *testing.T) {
+	instance, err := shardedmap.Open(t.TempDir())
+	require.NoError(t, err)
+
+	assert.NoError(t, instance.Set("a", 1))
+	assert.Error(t, instance.Set("unencodable", func() {}))
+	assert.Error(t, instance.SetMany(map[string]interface{}{"b": 2, "unencodable": func() {}}))
+	assert.False(t, instance.CompareAndSwap("a", 1, func() {}))
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 2}, instance.All())
+
+	assert.NoError(t, instance.Close())
+
+	assert.ErrorIs(t, instance.Set("c", 3), shardedmap.ErrClosed)
+	assert.ErrorIs(t, instance.SetMany(map[string]interface{}{"c": 3}), shardedmap.ErrClosed)
+	_, _, err = instance.GetOrSet("c", 3)
+	assert.ErrorIs(t, err, shardedmap.ErrClosed)
+	_, err = instance.Upsert("a", func(interface{}, bool) interface{} { return 10 })
+	assert.ErrorIs(t, err, shardedmap.ErrClosed)
+	_, _, err = instance.Compute("a", func(interface{}, bool) (interface{}, bool) { return nil, false })
+	assert.ErrorIs(t, err, shardedmap.ErrClosed)
+	assert.ErrorIs(t, instance.Update(func(tx *shardedmap.Tx) error { return tx.Set("c", 3) }), shardedmap.ErrClosed)
+	assert.False(t, instance.CompareAndSwap("a", 1, 10))
+	assert.False(t, instance.CompareAndDelete("a", 1))
+	instance.Remove("a")
+	instance.RemoveMany([]string{"b"})
+	instance.Clear()
+
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 2}, instance.All())
+}
+
+func TestRemovingAbsentKeyIsNotLogged(t *testing.T) {
+	dir := t.TempDir()
+
+	instance, err := shardedmap.Open(dir)
+	require.NoError(t, err)
+
+	info, err := os.Stat(filepath.Join(dir, "wal"))
+	require.NoError(t, err)
+
+	_, exists, err := instance.Compute("absent", func(interface{}, bool) (interface{}, bool) { return nil, false })
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	instance.Remove("absent")
+	instance.RemoveMany([]string{"absent", "other"})
+
+	after, err := os.Stat(filepath.Join(dir, "wal"))
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), after.Size())
+	assert.NoError(t, instance.Close())
+}
+
+func TestOpenIgnoresIncompleteRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	instance, err := shardedmap.Open(dir, shardedmap.WithWALSync(shardedmap.WALSyncNever, 0))
+	require.NoError(t, err)
+	assert.NoError(t, instance.Set("a", 1))
+	assert.NoError(t, instance.Set("b", 2))
+	assert.NoError(t, instance.Close())
+
+	// Cut the last record in half like a crash during an append
+	path := filepath.Join(dir, "wal")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-3))
+
+	restored, err := shardedmap.Open(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": 1}, restored.All())
+
+	// New records are appended after the last complete record
+	assert.NoError(t, restored.Set("c", 3))
+	assert.NoError(t, restored.Close())
+
+	restored, err = shardedmap.Open(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": 1, "c": 3}, restored.All())
+	assert.NoError(t, restored.Close())
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+
+	instance, err := shardedmap.Open(dir, shardedmap.WithWALSync(shardedmap.WALSyncInterval, time.Millisecond))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < 200; i++ {
+				assert.NoError(t, instance.Set(fmt.Sprintf("%d-%d", w, i%50), i))
+			}
+		}(w)
+	}
+
+	assert.NoError(t, instance.Compact())
+	wg.Wait()
+	assert.NoError(t, instance.Compact())
+
+	expected := instance.All()
+	assert.NoError(t, instance.Close())
+
+	_, err = os.Stat(filepath.Join(dir, "snapshot"))
+	assert.NoError(t, err)
+
+	restored, err := shardedmap.Open(dir)
+	require.NoError(t, err)
+	assert.Equal(t, expected, restored.All())
+	assert.NoError(t, restored.Close())
+}
+
+func TestAutomaticCompaction(t *testing.T) {
+	dir := t.TempDir()
+
+	instance, err := shardedmap.Open(dir, shardedmap.WithWALCompaction(1024))
+	require.NoError(t, err)
+
+	for i := 0; i < 500; i++ {
+		assert.NoError(t, instance.Set(fmt.Sprint(i%10), i))
+	}
+
+	assert.NoError(t, instance.Close())
+
+	_, err = os.Stat(filepath.Join(dir, "snapshot"))
+	assert.NoError(t, err)
+
+	// Compaction runs in the background, so the log may have grown past the limit meanwhile. Without
+	// compaction, it would hold about 11 KiB.
+	info, err := os.Stat(filepath.Join(dir, "wal"))
+	require.NoError(t, err)
+	assert.Less(t, info.Size(), int64(5*1024))
+
+	restored, err := shardedmap.Open(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 10, restored.Count())
+	assert.Equal(t, 499, restored.MustGet("9"))
+	assert.NoError(t, restored.Close())
+}
+
+func TestOpenFinishesInterruptedCompaction(t *testing.T) {
+	dir := t.TempDir()
+
+	instance, err := shardedmap.Open(dir)
+	require.NoError(t, err)
+	assert.NoError(t, instance.Set("a", 1))
+	assert.NoError(t, instance.Set("b", 2))
+	assert.NoError(t, instance.Close())
+
+	// A compaction renamed the log, but did not write the snapshot
+	require.NoError(t, os.Rename(filepath.Join(dir, "wal"), filepath.Join(dir, "wal.old")))
+
+	restored, err := shardedmap.Open(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 2}, restored.All())
+	assert.NoError(t, restored.Close())
+
+	_, err = os.Stat(filepath.Join(dir, "wal.old"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	restored, err = shardedmap.Open(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 2}, restored.All())
+	assert.NoError(t, restored.Close())
+}
@@ -0,0 +1,281 @@
+package shardedmap
+
+//go:generate stringer -type=EventType -trimprefix=Event
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// EventType describes the change of a Map reported by an Event.
+type EventType int
+
+const (
+	// EventSet is emitted for every entry stored by Set and all other operations that store entries.
+	EventSet EventType = iota
+	// EventRemove is emitted for entries removed by Remove and all other operations that remove entries.
+	EventRemove
+	// EventExpire is emitted for entries removed after their TTL passed.
+	EventExpire
+	// EventEvict is emitted for entries evicted to stay within the capacity of a shard.
+	EventEvict
+	// EventClear is emitted once for every call to Map.Clear. It passes every WatchFilter.
+	EventClear
+)
+
+// Event describes a change of a Map, see Map.Watch.
+type Event struct {
+	Type EventType
+	// Key is the key of the changed entry. It is empty for EventClear.
+	Key string
+	// Value is the stored value for EventSet and the value that left the Map otherwise.
+	Value interface{}
+	// Version is the version of Value, see Tuple.GetVersion. Events for the same key written concurrently may be
+	// delivered out of order, the version tells which change is the latest.
+	Version uint64
+}
+
+// WatchFilter selects the keys a subscriber of Map.Watch receives events for.
+type WatchFilter func(key string) bool
+
+// WatchPrefix returns a WatchFilter that selects all keys starting with prefix.
+func WatchPrefix(prefix string) WatchFilter {
+	return func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	}
+}
+
+// OverflowPolicy defines what happens to an event if the buffer of a subscriber is full.
+type OverflowPolicy int
+
+const (
+	// OverflowDrop drops the event. It is the default.
+	OverflowDrop OverflowPolicy = iota
+	// OverflowBlock makes the writer wait until the subscriber receives the event or the subscription ends.
+	// Events for removed, expired and evicted entries are sent while the writer holds the lock of its shard, so
+	// the subscriber must not write to the Map.
+	OverflowBlock
+	// OverflowDisconnect ends the subscription and closes its channel.
+	OverflowDisconnect
+)
+
+// WatchOption configures a subscription of Map.Watch.
+type WatchOption func(s *subscriber)
+
+// WithWatchBuffer sets the number of events buffered for a subscriber. Defaults to DefaultWatchBufferSize.
+func WithWatchBuffer(size int) WatchOption {
+	return func(s *subscriber) {
+		s.ch = make(chan Event, size)
+	}
+}
+
+// WithOverflowPolicy sets what happens to events if the buffer of the subscriber is full.
+// Defaults to OverflowDrop.
+func WithOverflowPolicy(policy OverflowPolicy) WatchOption {
+	return func(s *subscriber) {
+		s.overflow = policy
+	}
+}
+
+// Watch subscribes to the changes of all keys selected by filter, or of all keys if filter is nil. It returns a
+// channel of events that is closed once ctx is done, the subscription is disconnected by OverflowDisconnect or
+// the Map is closed.
+//
+// Events are emitted by the writing goroutine right after the change, so receivers see them shortly after the
+// change is visible. The overflow policy decides what happens if the receiver falls behind. Without
+// subscribers, writes only check an atomic counter.
+func (m *Map) Watch(ctx context.Context, filter WatchFilter, opts ...WatchOption) <-chan Event {
+	s := &subscriber{ //nolint:exhaustivestruct
+		ch:     make(chan Event, DefaultWatchBufferSize),
+		filter: filter,
+		done:   make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if !m.watchers.add(s) {
+		s.stop()
+		s.end()
+
+		return s.ch
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.done:
+		}
+
+		// Release a writer blocked by OverflowBlock before waiting for the lock of the hub
+		s.stop()
+		m.watchers.remove(s)
+		s.end()
+	}()
+
+	return s.ch
+}
+
+// watchHub delivers events to the subscribers of a Map.
+type watchHub struct {
+	mu     sync.RWMutex
+	subs   map[*subscriber]struct{}
+	count  atomic.Int32
+	closed bool
+}
+
+func (h *watchHub) add(s *subscriber) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return false
+	}
+
+	if h.subs == nil {
+		h.subs = make(map[*subscriber]struct{})
+	}
+
+	h.subs[s] = struct{}{}
+	h.count.Add(1)
+
+	return true
+}
+
+func (h *watchHub) remove(s *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		h.count.Add(-1)
+	}
+}
+
+// close ends all subscriptions and rejects new ones.
+func (h *watchHub) close() {
+	h.mu.RLock()
+	for s := range h.subs {
+		s.stop()
+	}
+	h.mu.RUnlock()
+
+	h.mu.Lock()
+	h.closed = true
+	h.mu.Unlock()
+}
+
+// publish sends an event for tuple to all subscribers. tuple is nil for EventClear.
+func (h *watchHub) publish(typ EventType, tuple ShardTuple) {
+	if h.count.Load() == 0 {
+		return
+	}
+
+	e := Event{Type: typ} //nolint:exhaustivestruct
+	if tuple != nil {
+		e.Key, e.Value, e.Version = tuple.GetKey(), tuple.GetValue(), versionOf(tuple)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for s := range h.subs {
+		s.send(e)
+	}
+}
+
+// publishEviction publishes the event for a tuple that left a shard. Cleared tuples are covered by EventClear.
+func (h *watchHub) publishEviction(tuple ShardTuple, reason EvictionReason) {
+	switch reason {
+	case EvictionReasonRemoved:
+		h.publish(EventRemove, tuple)
+	case EvictionReasonExpired:
+		h.publish(EventExpire, tuple)
+	case EvictionReasonCapacity:
+		h.publish(EventEvict, tuple)
+	case EvictionReasonCleared:
+	}
+}
+
+// notifySet publishes a stored tuple to the subscribers of Watch and wakes the goroutines waiting for its key in
+// WaitFor. Writers call it once the tuple is stored and logged and the gate of the shard is released, so neither
+// subscribers nor waiters delay other writers of the shard. It does nothing for a nil tuple.
+func (m *Map) notifySet(tuple ShardTuple) {
+	if tuple == nil {
+		return
+	}
+
+	m.watchers.publish(EventSet, tuple)
+	m.wakeWaiters(tuple.GetKey())
+}
+
+// releaseAndNotify releases the gate of shard like releaseShard and then calls notifySet for *stored. Writers defer
+// it and set stored once their write succeeded.
+func (m *Map) releaseAndNotify(shard *shardSlot, stored *ShardTuple) {
+	m.releaseShard(shard)
+	m.notifySet(*stored)
+}
+
+// subscriber is a subscription of Map.Watch.
+type subscriber struct {
+	ch       chan Event
+	filter   WatchFilter
+	overflow OverflowPolicy
+	// done is closed once the subscription ends.
+	done     chan struct{}
+	doneOnce sync.Once
+	// mu serializes sending to and closing ch.
+	mu     sync.Mutex
+	closed bool
+}
+
+func (s *subscriber) send(e Event) {
+	if e.Type != EventClear && s.filter != nil && !s.filter(e.Key) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.ch <- e:
+		return
+	default:
+	}
+
+	switch s.overflow {
+	case OverflowBlock:
+		select {
+		case s.ch <- e:
+		case <-s.done:
+		}
+	case OverflowDisconnect:
+		s.closed = true
+		close(s.ch)
+		s.stop()
+	case OverflowDrop:
+	}
+}
+
+// stop signals the end of the subscription.
+func (s *subscriber) stop() {
+	s.doneOnce.Do(func() { close(s.done) })
+}
+
+// end closes the channel of the subscription once no event is sent anymore.
+func (s *subscriber) end() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
@@ -0,0 +1,151 @@
+package shardedmap_test
+
+import (
+	"context"
+	"github.com/dtomasi/shardedmap"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// receive returns the next event or fails after a second.
+func receive(t *testing.T, events <-chan shardedmap.Event) shardedmap.Event {
+	t.Helper()
+
+	select {
+	case e, ok := <-events:
+		assert.True(t, ok, "channel closed")
+
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+
+	return shardedmap.Event{} //nolint:exhaustivestruct
+}
+
+func TestWatch(t *testing.T) {
+	instance := shardedmap.New(
+		shardedmap.WithShardCount(1),
+		shardedmap.WithMaxEntries(2),
+		shardedmap.WithCustomShardProvider(shardedmap.NewLRUShard),
+		shardedmap.WithJanitor(time.Millisecond),
+	)
+	defer instance.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := instance.Watch(ctx, nil)
+
+	assert.NoError(t, instance.Set("a", 1))
+	e := receive(t, events)
+	assert.Equal(t, shardedmap.EventSet, e.Type)
+	assert.Equal(t, "a", e.Key)
+	assert.Equal(t, 1, e.Value)
+
+	_, version, _ := instance.GetWithVersion("a")
+	assert.Equal(t, version, e.Version)
+
+	instance.Remove("a")
+	instance.Remove("a")
+	assert.Equal(t, shardedmap.Event{Type: shardedmap.EventRemove, Key: "a", Value: 1, Version: version},
+		receive(t, events))
+
+	assert.NoError(t, instance.SetWithTTL("ttl", 2, time.Millisecond))
+	assert.Equal(t, shardedmap.EventSet, receive(t, events).Type)
+	assert.Equal(t, shardedmap.EventExpire, receive(t, events).Type)
+
+	for _, key := range []string{"b", "c"} {
+		assert.NoError(t, instance.Set(key, key))
+		assert.Equal(t, key, receive(t, events).Key)
+	}
+
+	// The shard is full, the least recently used entry is evicted before the new one is stored
+	assert.NoError(t, instance.Set("d", "d"))
+	e = receive(t, events)
+	assert.Equal(t, shardedmap.EventEvict, e.Type)
+	assert.Equal(t, "b", e.Key)
+	assert.Equal(t, "d", receive(t, events).Key)
+
+	instance.Clear()
+	assert.Equal(t, shardedmap.Event{Type: shardedmap.EventClear}, receive(t, events)) //nolint:exhaustivestruct
+
+	cancel()
+
+	assert.Eventually(t, func() bool {
+		_, ok := <-events
+
+		return !ok
+	}, time.Second, time.Millisecond)
+}
+
+func TestWatchFilter(t *testing.T) {
+	instance := shardedmap.New()
+	events := instance.Watch(context.Background(), shardedmap.WatchPrefix("user/"))
+
+	assert.NoError(t, instance.Set("session/1", 1))
+	assert.NoError(t, instance.Set("user/1", 1))
+	assert.Equal(t, "user/1", receive(t, events).Key)
+
+	// Every subscriber receives EventClear
+	instance.Clear()
+	assert.Equal(t, shardedmap.EventClear, receive(t, events).Type)
+
+	assert.NoError(t, instance.Close())
+
+	_, ok := <-events
+	assert.False(t, ok)
+
+	// Subscriptions of a closed Map end immediately
+	_, ok = <-instance.Watch(context.Background(), nil)
+	assert.False(t, ok)
+}
+
+func TestWatchSkipsFailedWrites(t *testing.T) {
+	instance, err := shardedmap.Open(t.TempDir())
+	assert.NoError(t, err)
+
+	events := instance.Watch(context.Background(), nil)
+
+	assert.Error(t, instance.Set("unencodable", func() {}))
+	assert.NoError(t, instance.Set("a", 1))
+	assert.Equal(t, "a", receive(t, events).Key)
+
+	assert.NoError(t, instance.Close())
+}
+
+func TestWatchOverflowPolicies(t *testing.T) {
+	instance := shardedmap.New()
+	defer instance.Close()
+
+	dropping := instance.Watch(context.Background(), nil, shardedmap.WithWatchBuffer(1))
+	disconnecting := instance.Watch(context.Background(), nil,
+		shardedmap.WithWatchBuffer(1), shardedmap.WithOverflowPolicy(shardedmap.OverflowDisconnect))
+	blocking := instance.Watch(context.Background(), nil,
+		shardedmap.WithWatchBuffer(0), shardedmap.WithOverflowPolicy(shardedmap.OverflowBlock))
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for _, key := range []string{"a", "b", "c"} {
+			assert.NoError(t, instance.Set(key, key))
+		}
+	}()
+
+	for _, key := range []string{"a", "b", "c"} {
+		assert.Equal(t, key, receive(t, blocking).Key)
+	}
+
+	<-done
+
+	assert.Equal(t, "a", receive(t, dropping).Key)
+	assert.Len(t, dropping, 0)
+
+	assert.Equal(t, "a", receive(t, disconnecting).Key)
+
+	_, ok := <-disconnecting
+	assert.False(t, ok)
+}
@@ -0,0 +1,100 @@
+package shardedmap
+
+import (
+	"math/bits"
+)
+
+const (
+	xxPrime64v1 = 11400714785074694791
+	xxPrime64v2 = 14029467366897019727
+	xxPrime64v3 = 1609587929392839161
+	xxPrime64v4 = 9650029242287828579
+	xxPrime64v5 = 2870177450012600261
+)
+
+// HashXXHash64 hashes key with XXH64 and seed 0. It has a good avalanche for similar keys like sequential IDs.
+func HashXXHash64(key string) uint {
+	return HashXXHash64Seeded(0, key)
+}
+
+// HashXXHash64Seeded hashes key with XXH64 and the given seed.
+func HashXXHash64Seeded(seed uint64, key string) uint {
+	var (
+		n    = len(key)
+		i    int
+		hash uint64
+	)
+
+	if n >= 32 { //nolint:gomnd
+		v1 := seed + xxPrime64v1 + xxPrime64v2
+		v2 := seed + xxPrime64v2
+		v3 := seed
+		v4 := seed - xxPrime64v1
+
+		for ; i+32 <= n; i += 32 {
+			v1 = xxRound64(v1, readUint64(key, i))
+			v2 = xxRound64(v2, readUint64(key, i+8))
+			v3 = xxRound64(v3, readUint64(key, i+16))
+			v4 = xxRound64(v4, readUint64(key, i+24))
+		}
+
+		hash = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) +
+			bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		hash = xxMergeRound64(hash, v1)
+		hash = xxMergeRound64(hash, v2)
+		hash = xxMergeRound64(hash, v3)
+		hash = xxMergeRound64(hash, v4)
+	} else {
+		hash = seed + xxPrime64v5
+	}
+
+	hash += uint64(n)
+
+	for ; i+8 <= n; i += 8 {
+		hash ^= xxRound64(0, readUint64(key, i))
+		hash = bits.RotateLeft64(hash, 27)*xxPrime64v1 + xxPrime64v4
+	}
+
+	if i+4 <= n {
+		hash ^= uint64(readUint32(key, i)) * xxPrime64v1
+		hash = bits.RotateLeft64(hash, 23)*xxPrime64v2 + xxPrime64v3
+		i += 4
+	}
+
+	for ; i < n; i++ {
+		hash ^= uint64(key[i]) * xxPrime64v5
+		hash = bits.RotateLeft64(hash, 11) * xxPrime64v1
+	}
+
+	hash ^= hash >> 33
+	hash *= xxPrime64v2
+	hash ^= hash >> 29
+	hash *= xxPrime64v3
+	hash ^= hash >> 32
+
+	return uint(hash)
+}
+
+func xxRound64(acc, input uint64) uint64 {
+	acc += input * xxPrime64v2
+	acc = bits.RotateLeft64(acc, 31)
+
+	return acc * xxPrime64v1
+}
+
+func xxMergeRound64(acc, val uint64) uint64 {
+	acc ^= xxRound64(0, val)
+
+	return acc*xxPrime64v1 + xxPrime64v4
+}
+
+// readUint64 reads a little endian uint64 from s at i without converting s to a byte slice.
+func readUint64(s string, i int) uint64 {
+	return uint64(s[i]) | uint64(s[i+1])<<8 | uint64(s[i+2])<<16 | uint64(s[i+3])<<24 |
+		uint64(s[i+4])<<32 | uint64(s[i+5])<<40 | uint64(s[i+6])<<48 | uint64(s[i+7])<<56
+}
+
+// readUint32 reads a little endian uint32 from s at i without converting s to a byte slice.
+func readUint32(s string, i int) uint32 {
+	return uint32(s[i]) | uint32(s[i+1])<<8 | uint32(s[i+2])<<16 | uint32(s[i+3])<<24
+}